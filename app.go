@@ -1,18 +1,29 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	_ "image/jpeg"
 	_ "image/png"
+	"io"
+	"log/slog"
 	"math"
 	"net/http"
+	"os"
+	"reflect"
 	"slices"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // themes
@@ -28,57 +39,318 @@ const (
 	STYLE_HIGH_CONTRAST = "contrast"
 	STYLE_EDGE_CONTRAST = "edge"
 	STYLE_SMOOTH        = "smooth"
+	STYLE_LINE_ART      = "lineart"
+	STYLE_BLUE_NOISE    = "bluenoise"
+	STYLE_HALFTONE      = "halftone"
+	STYLE_CUSTOM        = "custom"
 )
 
+// edge detection methods
+const (
+	EDGE_SOBEL = "sobel"
+	EDGE_CANNY = "canny"
+)
+
+// equalization methods
+const (
+	EQUALIZE_NONE   = "none"
+	EQUALIZE_GLOBAL = "global"
+	EQUALIZE_CLAHE  = "clahe"
+)
+
+// response formats
+const (
+	FORMAT_JSON     = "json"
+	FORMAT_TEXT     = "text"
+	FORMAT_DETAILED = "detailed"
+)
+
+// version of the detailed response format, incremented on breaking changes
+const RESPONSE_VERSION = 1
+
 // defaults
 const (
-	DEFAULT_EXPOSURE = 50.0
-	DEFAULT_INVERTED = false
-	DEFAULT_STYLE    = STYLE_NORMAL
-	DEFAULT_THEME    = THEME_LIGHT
-	DEFAULT_WIDTH    = 60
+	DEFAULT_EXPOSURE          = 50.0
+	DEFAULT_INVERTED          = false
+	DEFAULT_STYLE             = STYLE_NORMAL
+	DEFAULT_THEME             = THEME_LIGHT
+	DEFAULT_WIDTH             = 60
+	DEFAULT_BRIGHTNESS        = 0.0
+	DEFAULT_CONTRAST          = 0.0
+	DEFAULT_GAMMA             = 1.0
+	DEFAULT_BLACK_POINT       = 0.0
+	DEFAULT_WHITE_POINT       = 100.0
+	DEFAULT_EQUALIZE          = EQUALIZE_NONE
+	DEFAULT_TILE_SIZE         = 32
+	DEFAULT_CLIP_LIMIT        = 2.0
+	DEFAULT_EDGE_METHOD       = EDGE_CANNY
+	DEFAULT_EDGE_LOW          = 10.0
+	DEFAULT_EDGE_HIGH         = 25.0
+	DEFAULT_BLUR              = 1.0
+	DEFAULT_FILL              = 0.0
+	DEFAULT_SHARPEN_RADIUS    = 1.0
+	DEFAULT_SHARPEN_AMOUNT    = 0.0
+	DEFAULT_SHARPEN_THRESHOLD = 0.0
+	DEFAULT_CELL_SIZE         = 6
+	DEFAULT_ANGLE             = 45.0
+	DEFAULT_DIFFUSION         = 100.0
+	DEFAULT_CLAMPED           = false
+	DEFAULT_CLAMP_MIN         = 0.0
+	DEFAULT_CLAMP_MAX         = 100.0
+	DEFAULT_FORMAT            = FORMAT_JSON
 )
 
 // ascii properties
 const (
 	CHAR_WIDTH  = 2
 	CHAR_HEIGHT = 4
+	CHAR_BYTES  = 3 // every brail character (U+2800 to U+28FF) is encoded as 3 bytes of UTF-8
 )
 
 // limits
 const (
-	MIN_EXPOSURE = 0.0
-	MAX_EXPOSURE = 100.0
-	MIN_LENGTH   = 1
-	MAX_LENGTH   = 500
+	MIN_EXPOSURE          = 0.0
+	MAX_EXPOSURE          = 100.0
+	MIN_LENGTH            = 1
+	MAX_LENGTH            = 500
+	MIN_BRIGHTNESS        = -100.0
+	MAX_BRIGHTNESS        = 100.0
+	MIN_CONTRAST          = -100.0
+	MAX_CONTRAST          = 100.0
+	MIN_GAMMA             = 0.1
+	MAX_GAMMA             = 10.0
+	MIN_LEVEL             = 0.0
+	MAX_LEVEL             = 100.0
+	MIN_TILE_SIZE         = 8
+	MAX_TILE_SIZE         = 256
+	MIN_CLIP_LIMIT        = 1.0
+	MAX_CLIP_LIMIT        = 10.0
+	MIN_EDGE              = 0.0
+	MAX_EDGE              = 100.0
+	MIN_BLUR              = 0.0
+	MAX_BLUR              = 5.0
+	MIN_FILL              = 0.0
+	MAX_FILL              = 100.0
+	MIN_SHARPEN_RADIUS    = 0.1
+	MAX_SHARPEN_RADIUS    = 5.0
+	MIN_SHARPEN_AMOUNT    = 0.0
+	MAX_SHARPEN_AMOUNT    = 500.0
+	MIN_SHARPEN_THRESHOLD = 0.0
+	MAX_SHARPEN_THRESHOLD = 100.0
+	MIN_CELL_SIZE         = 2
+	MAX_CELL_SIZE         = 32
+	MIN_ANGLE             = 0.0
+	MAX_ANGLE             = 90.0
+	MAX_KERNEL_NODES      = 16
+	MAX_KERNEL_OFFSET     = 4
+	MIN_DIFFUSION         = 0.0
+	MAX_DIFFUSION         = 100.0
+	MIN_CLAMP             = 0.0
+	MAX_CLAMP             = 100.0
+)
+
+// raw image content types
+const (
+	MIME_PNG          = "image/png"
+	MIME_JPEG         = "image/jpeg"
+	MIME_JPG          = "image/jpg"
+	MIME_OCTET_STREAM = "application/octet-stream"
+	MIME_DETAILED     = "application/vnd.image2ascii.v1+json"
 )
 
 // form field names [ensure matches FormData struct]
 const (
-	FORM_THEME_NAME    = "theme"
-	FORM_WIDTH_NAME    = "width"
-	FORM_HEIGHT_NAME   = "height"
-	FORM_INVERT_NAME   = "invert"
-	FORM_EXPOSURE_NAME = "exposure"
-	FORM_STYLE_NAME    = "style"
-	FORM_IMAGE_NAME    = "image"
+	FORM_THEME_NAME             = "theme"
+	FORM_WIDTH_NAME             = "width"
+	FORM_HEIGHT_NAME            = "height"
+	FORM_INVERT_NAME            = "invert"
+	FORM_EXPOSURE_NAME          = "exposure"
+	FORM_STYLE_NAME             = "style"
+	FORM_IMAGE_NAME             = "image"
+	FORM_BRIGHTNESS_NAME        = "brightness"
+	FORM_CONTRAST_NAME          = "contrast"
+	FORM_GAMMA_NAME             = "gamma"
+	FORM_BLACK_POINT_NAME       = "black_point"
+	FORM_WHITE_POINT_NAME       = "white_point"
+	FORM_EQUALIZE_NAME          = "equalize"
+	FORM_TILE_SIZE_NAME         = "tile_size"
+	FORM_CLIP_LIMIT_NAME        = "clip_limit"
+	FORM_EDGE_METHOD_NAME       = "edge_method"
+	FORM_EDGE_LOW_NAME          = "edge_low"
+	FORM_EDGE_HIGH_NAME         = "edge_high"
+	FORM_BLUR_NAME              = "blur"
+	FORM_FILL_NAME              = "fill"
+	FORM_SHARPEN_RADIUS_NAME    = "sharpen_radius"
+	FORM_SHARPEN_AMOUNT_NAME    = "sharpen_amount"
+	FORM_SHARPEN_THRESHOLD_NAME = "sharpen_threshold"
+	FORM_CELL_SIZE_NAME         = "cell_size"
+	FORM_ANGLE_NAME             = "angle"
+	FORM_KERNEL_NAME            = "kernel"
+	FORM_DIFFUSION_NAME         = "diffusion"
+	FORM_CLAMP_NAME             = "clamp"
+	FORM_CLAMP_MIN_NAME         = "clamp_min"
+	FORM_CLAMP_MAX_NAME         = "clamp_max"
+	FORM_FORMAT_NAME            = "format"
+)
+
+// validation error codes
+const (
+	ERROR_OUT_OF_RANGE   = "out_of_range"
+	ERROR_INVALID_OPTION = "invalid_option"
+	ERROR_CONFLICT       = "conflict"
+	ERROR_REQUIRED       = "required"
+	ERROR_INVALID_KERNEL = "invalid_kernel"
+	ERROR_INVALID_TYPE   = "invalid_type"
+	ERROR_TOO_LARGE      = "too_large"
 )
 
 // CheckboxBool struct for form checkboxes
 type CheckboxBool string
 
+// value of a checked checkbox
+const CHECKBOX_CHECKED CheckboxBool = "on"
+
 func (cb CheckboxBool) Bool() bool {
-	return cb == "on"
+	return cb == CHECKBOX_CHECKED
+}
+
+// UnmarshalJSON allows CheckboxBool to be parsed from a JSON boolean, in addition to the checkbox-style string "on".
+func (cb *CheckboxBool) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		if b {
+			*cb = CHECKBOX_CHECKED
+		} else {
+			*cb = ""
+		}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("invalid checkbox: must be either a boolean or a string")
+	}
+	*cb = CheckboxBool(s)
+	return nil
+}
+
+// MarshalJSON encodes CheckboxBool as a JSON boolean.
+func (cb CheckboxBool) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cb.Bool())
 }
 
 // FormData struct to parse form body
 type FormData struct {
-	Theme    *string      `form:"theme"`
-	Width    *int         `form:"width"`
-	Height   *int         `form:"height"`
-	IsInvert CheckboxBool `form:"invert"`
-	Exposure *float64     `form:"exposure"`
-	Style    *string      `form:"style"`
+	Theme            *string       `form:"theme" json:"theme"`
+	Width            *int          `form:"width" json:"width"`
+	Height           *int          `form:"height" json:"height"`
+	IsInvert         CheckboxBool  `form:"invert" json:"invert"`
+	Exposure         *float64      `form:"exposure" json:"exposure"`
+	Style            *string       `form:"style" json:"style"`
+	Brightness       *float64      `form:"brightness" json:"brightness"`
+	Contrast         *float64      `form:"contrast" json:"contrast"`
+	Gamma            *float64      `form:"gamma" json:"gamma"`
+	BlackPoint       *float64      `form:"black_point" json:"black_point"`
+	WhitePoint       *float64      `form:"white_point" json:"white_point"`
+	Equalize         *string       `form:"equalize" json:"equalize"`
+	TileSize         *int          `form:"tile_size" json:"tile_size"`
+	ClipLimit        *float64      `form:"clip_limit" json:"clip_limit"`
+	EdgeMethod       *string       `form:"edge_method" json:"edge_method"`
+	EdgeLow          *float64      `form:"edge_low" json:"edge_low"`
+	EdgeHigh         *float64      `form:"edge_high" json:"edge_high"`
+	Blur             *float64      `form:"blur" json:"blur"`
+	Fill             *float64      `form:"fill" json:"fill"`
+	SharpenRadius    *float64      `form:"sharpen_radius" json:"sharpen_radius"`
+	SharpenAmount    *float64      `form:"sharpen_amount" json:"sharpen_amount"`
+	SharpenThreshold *float64      `form:"sharpen_threshold" json:"sharpen_threshold"`
+	CellSize         *int          `form:"cell_size" json:"cell_size"`
+	Angle            *float64      `form:"angle" json:"angle"`
+	Kernel           *DitherKernel `form:"kernel" json:"kernel"`
+	Diffusion        *float64      `form:"diffusion" json:"diffusion"`
+	IsClamp          CheckboxBool  `form:"clamp" json:"clamp"`
+	ClampMin         *float64      `form:"clamp_min" json:"clamp_min"`
+	ClampMax         *float64      `form:"clamp_max" json:"clamp_max"`
+	Format           *string       `form:"format" json:"format"`
+}
+
+// JSON request struct to parse a JSON request body, with the image encoded as base64
+type JSONRequest struct {
+	FormData
+	Image string `json:"image"`
+}
+
+// Image dimensions struct to describe the size of an image, in pixels
+type ImageDimensions struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Detailed response struct, returned when the detailed format is requested
+type DetailedResponse struct {
+	Version          int             `json:"version"`
+	Ascii            []string        `json:"ascii"`
+	Settings         FormData        `json:"settings"`
+	Source           ImageDimensions `json:"source"`
+	Characters       int             `json:"characters"`
+	ProcessingTimeMs float64         `json:"processing_time_ms"`
+}
+
+// Field error struct to describe a single form field that failed validation, in a machine readable format
+type FieldError struct {
+	Field   string   `json:"field"`
+	Code    string   `json:"code"`
+	Message string   `json:"message"`
+	Value   any      `json:"value,omitempty"`
+	Min     any      `json:"min,omitempty"`
+	Max     any      `json:"max,omitempty"`
+	Options []string `json:"options,omitempty"`
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// Validation error type to collect every form field that failed validation
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldError := range e {
+		messages[i] = fieldError.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add appends the field error(s) described by err to e, and returns the result. A nil err leaves e unchanged.
+func (e ValidationError) Add(err error) ValidationError {
+	var fieldError FieldError
+	var validationError ValidationError
+
+	switch {
+	case err == nil:
+	case errors.As(err, &validationError):
+		e = append(e, validationError...)
+	case errors.As(err, &fieldError):
+		e = append(e, fieldError)
+	default:
+		e = append(e, FieldError{Message: err.Error()})
+	}
+	return e
+}
+
+// Err returns e as an error if any field failed validation, nil otherwise.
+func (e ValidationError) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error response struct, returned when a request fails. Errors is only set when validation fails.
+type ErrorResponse struct {
+	Error     string       `json:"error"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
 }
 
 // Relative Position struct for DitherNode
@@ -93,10 +365,40 @@ type DitherNode struct {
 	value            float64
 }
 
+// Threshold map function type, which returns a threshold between 0.0 and 1.0 for a point in the image
+type ThresholdMap func(point Point) float64
+
+// Kernel node struct to describe a single, user-defined element of a DitherKernel
+type KernelNode struct {
+	Dx     int     `json:"dx"`
+	Dy     int     `json:"dy"`
+	Weight float64 `json:"weight"`
+}
+
+// Dither kernel struct to parse a user-defined error diffusion kernel. Each node receives Weight / Divisor of the error.
+type DitherKernel struct {
+	Nodes   []KernelNode `json:"nodes"`
+	Divisor float64      `json:"divisor"`
+}
+
+// UnmarshalParam allows DitherKernel to be parsed from a form field containing a JSON object.
+func (k *DitherKernel) UnmarshalParam(param string) error {
+	if err := json.Unmarshal([]byte(param), k); err != nil {
+		return errors.New("invalid kernel: must be a JSON object with `nodes` and `divisor` fields")
+	}
+	return nil
+}
+
 // Encoding settings struct to describe how to encode image based on style
 type EncodingSettings struct {
 	UsePercievedBrightness bool
+	DetectEdges            bool
 	DitherNodes            []DitherNode
+	ThresholdMap           ThresholdMap
+	DiffusionStrength      float64
+	IsClamped              bool
+	ClampMin               float64
+	ClampMax               float64
 }
 
 // Point struct for representing position in image
@@ -107,8 +409,9 @@ type Point struct {
 
 // Option struct to represent an option tag in HTML
 type Option struct {
-	Value string
-	Label string
+	Value       string `json:"value"`
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
 }
 
 // getThemes returns the valid web themes.
@@ -118,7 +421,27 @@ func getThemes() []string {
 
 // getStyles returns the valid encoding styles.
 func getStyles() []string {
-	return []string{STYLE_NORMAL, STYLE_BRIGHTNESS, STYLE_HIGH_CONTRAST, STYLE_EDGE_CONTRAST, STYLE_SMOOTH}
+	return getOptionValues(getStyleOptions())
+}
+
+// getEdgeMethods returns the valid edge detection methods.
+func getEdgeMethods() []string {
+	return getOptionValues(getEdgeMethodOptions())
+}
+
+// getEqualizations returns the valid histogram equalization methods.
+func getEqualizations() []string {
+	return getOptionValues(getEqualizeOptions())
+}
+
+// getFormats returns the valid response formats.
+func getFormats() []string {
+	return []string{FORMAT_JSON, FORMAT_TEXT, FORMAT_DETAILED}
+}
+
+// getContentTypes returns the supported content types of a request body.
+func getContentTypes() []string {
+	return []string{binding.MIMEMultipartPOSTForm, binding.MIMEJSON, MIME_PNG, MIME_JPEG, MIME_JPG, MIME_OCTET_STREAM}
 }
 
 // getInvalidStylesError returns an error that specifies to the user than the style is invalid
@@ -126,6 +449,150 @@ func getInvalidStylesError() error {
 	return fmt.Errorf("invalid style: must be one of the following: %s", strings.Join(getStyles(), ", "))
 }
 
+// getFieldLabel converts a form field name to a human readable label, used in error messages.
+func getFieldLabel(field string) string {
+	return strings.ReplaceAll(field, "_", " ")
+}
+
+// newRangeError returns a FieldError describing a form field whose `value` does not fall between `minVal` and `maxVal`.
+// Non-finite values cannot be encoded as JSON, so they are described as text.
+func newRangeError(field string, value, minVal, maxVal any) FieldError {
+	if number, ok := value.(float64); ok && (math.IsNaN(number) || math.IsInf(number, 0)) {
+		value = fmt.Sprint(number)
+	}
+	return FieldError{
+		Field:   field,
+		Code:    ERROR_OUT_OF_RANGE,
+		Message: fmt.Sprintf("invalid %s: must be a number between %v & %v", getFieldLabel(field), minVal, maxVal),
+		Value:   value,
+		Min:     minVal,
+		Max:     maxVal,
+	}
+}
+
+// newOptionError returns a FieldError describing a form field whose `value` is not one of `options`.
+func newOptionError(field, value string, options []string) FieldError {
+	return FieldError{
+		Field:   field,
+		Code:    ERROR_INVALID_OPTION,
+		Message: fmt.Sprintf("invalid %s: must be one of the following: %s", getFieldLabel(field), strings.Join(options, ", ")),
+		Value:   value,
+		Options: options,
+	}
+}
+
+// newKernelError returns a FieldError describing an invalid dither kernel, with a message built from `format` and `args`.
+func newKernelError(code string, value any, format string, args ...any) FieldError {
+	return FieldError{
+		Field:   FORM_KERNEL_NAME,
+		Code:    code,
+		Message: "invalid kernel: " + fmt.Sprintf(format, args...),
+		Value:   value,
+	}
+}
+
+// getTypeDescription describes the values that can be parsed as `fieldType`, used in error messages.
+func getTypeDescription(fieldType reflect.Type) string {
+	if fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+
+	switch {
+	case fieldType == reflect.TypeFor[CheckboxBool]():
+		return "either a boolean or a string"
+	case fieldType == reflect.TypeFor[DitherKernel]():
+		return "a JSON object with `nodes` and `divisor` fields"
+	case fieldType.Kind() == reflect.Int:
+		return "an integer"
+	case fieldType.Kind() == reflect.Float64:
+		return "a number"
+	default:
+		return "a string"
+	}
+}
+
+// newTypeError returns a FieldError describing a form field whose `value` cannot be parsed as the type of the field.
+func newTypeError(field string, value any, fieldType reflect.Type) FieldError {
+	return FieldError{
+		Field:   field,
+		Code:    ERROR_INVALID_TYPE,
+		Message: fmt.Sprintf("invalid %s: must be %s", getFieldLabel(field), getTypeDescription(fieldType)),
+		Value:   value,
+	}
+}
+
+// getFormTypeErrors parses each form field in `values` on it's own, and returns a ValidationError describing every field
+// whose value cannot be parsed as the type of the field. Used to explain a failed form binding, which only reports the first
+// value that failed to parse, without it's field.
+func getFormTypeErrors(values map[string][]string) ValidationError {
+	errs := ValidationError{}
+	for _, field := range reflect.VisibleFields(reflect.TypeFor[FormData]()) {
+		name := field.Tag.Get("form")
+		value, ok := values[name]
+		if !ok || len(value) == 0 {
+			continue
+		}
+
+		var form FormData
+		if err := binding.MapFormWithTag(&form, map[string][]string{name: value}, "form"); err != nil {
+			errs = errs.Add(newTypeError(name, value[0], field.Type))
+		}
+	}
+	return errs
+}
+
+// getJSONTypeErrors parses each field of a JSON request `body` on it's own, and returns a ValidationError describing every
+// field whose value cannot be parsed as the type of the field. Used to explain a failed JSON binding, which only reports the
+// first value that failed to parse.
+// Returns an empty ValidationError if the body is not a JSON object.
+func getJSONTypeErrors(body []byte) ValidationError {
+	errs := ValidationError{}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(body, &values); err != nil {
+		return errs
+	}
+
+	for _, field := range reflect.VisibleFields(reflect.TypeFor[JSONRequest]()) {
+		name, _ := getJSONFieldName(field)
+		raw, ok := values[name]
+		if field.Anonymous || !ok {
+			continue
+		}
+
+		if err := json.Unmarshal(raw, reflect.New(field.Type).Interface()); err != nil {
+			var value any
+			json.Unmarshal(raw, &value)
+			errs = errs.Add(newTypeError(name, value, field.Type))
+		}
+	}
+	return errs
+}
+
+// newTooLargeError returns a FieldError describing a request body larger than `limit` bytes.
+func newTooLargeError(limit int64) FieldError {
+	return FieldError{
+		Field:   FORM_IMAGE_NAME,
+		Code:    ERROR_TOO_LARGE,
+		Message: fmt.Sprintf("image too large: request body must be at most %d bytes", limit),
+		Max:     limit,
+	}
+}
+
+// isBodyTooLarge determines whether err was caused by reading past the limit of a request body.
+func isBodyTooLarge(err error) bool {
+	var maxBytesError *http.MaxBytesError
+	return errors.As(err, &maxBytesError)
+}
+
+// getBindingError returns `fieldErrors` in place of `err`, a failed binding, if any field was found to be invalid, so that the
+// client can tell which fields to correct. Otherwise, returns err.
+func getBindingError(err error, fieldErrors ValidationError) error {
+	if len(fieldErrors) == 0 {
+		return err
+	}
+	return fieldErrors
+}
+
 // validateTheme ensures that the `theme` attribute of f is valid.
 // Returns error if validation fails, nil otherwise.
 // If theme is unset, update theme attribute to take on `defaultTheme`, return nil.
@@ -137,7 +604,7 @@ func validateTheme(f *FormData) error {
 		themes := getThemes()
 
 		if !slices.Contains(themes, theme) {
-			return fmt.Errorf("invalid theme: must be one of the following: %s", strings.Join(themes, ", "))
+			return newOptionError(FORM_THEME_NAME, theme, themes)
 		}
 	} else {
 		defaultTheme := DEFAULT_THEME
@@ -156,8 +623,9 @@ func validateExposure(f *FormData) error {
 	if f.Exposure != nil {
 		exposure := *f.Exposure
 
-		if exposure < MIN_EXPOSURE || exposure > MAX_EXPOSURE {
-			return fmt.Errorf("invalid exposure: must be a number between %f & %f", MIN_EXPOSURE, MAX_EXPOSURE)
+		// written as a negated range, so that NaN, which fails every comparison, is rejected
+		if !(exposure >= MIN_EXPOSURE && exposure <= MAX_EXPOSURE) {
+			return newRangeError(FORM_EXPOSURE_NAME, exposure, MIN_EXPOSURE, MAX_EXPOSURE)
 		}
 
 		reversedExposure := MAX_EXPOSURE - exposure
@@ -170,38 +638,346 @@ func validateExposure(f *FormData) error {
 	return nil
 }
 
+// validateFloatRange ensures that `value`, a float attribute of a FormData struct, is a number between `minVal` and `maxVal`.
+// Returns error if validation fails, nil otherwise.
+// If value is unset, update value to take on `defaultVal`, return nil.
+// If value is set, and validated, return nil.
+// If value is set, but not validated, return error, using `field` to identify the attribute.
+func validateFloatRange(value **float64, field string, minVal, maxVal, defaultVal float64) error {
+	if *value != nil {
+		// written as a negated range, so that NaN, which fails every comparison, is rejected
+		if !(**value >= minVal && **value <= maxVal) {
+			return newRangeError(field, **value, minVal, maxVal)
+		}
+	} else {
+		*value = &defaultVal
+	}
+
+	return nil
+}
+
+// validateBrightness ensures that the `brightness` attribute of f is valid.
+// Returns error if validation fails, nil otherwise.
+// If brightness is unset, update brightness attribute to take on default value, return nil.
+// If brightness is set, and validated, return nil.
+// If brightness is set, but not validated, return error.
+func validateBrightness(f *FormData) error {
+	return validateFloatRange(&f.Brightness, FORM_BRIGHTNESS_NAME, MIN_BRIGHTNESS, MAX_BRIGHTNESS, DEFAULT_BRIGHTNESS)
+}
+
+// validateContrast ensures that the `contrast` attribute of f is valid.
+// Returns error if validation fails, nil otherwise.
+// If contrast is unset, update contrast attribute to take on default value, return nil.
+// If contrast is set, and validated, return nil.
+// If contrast is set, but not validated, return error.
+func validateContrast(f *FormData) error {
+	return validateFloatRange(&f.Contrast, FORM_CONTRAST_NAME, MIN_CONTRAST, MAX_CONTRAST, DEFAULT_CONTRAST)
+}
+
+// validateGamma ensures that the `gamma` attribute of f is valid.
+// Returns error if validation fails, nil otherwise.
+// If gamma is unset, update gamma attribute to take on default value, return nil.
+// If gamma is set, and validated, return nil.
+// If gamma is set, but not validated, return error.
+func validateGamma(f *FormData) error {
+	return validateFloatRange(&f.Gamma, FORM_GAMMA_NAME, MIN_GAMMA, MAX_GAMMA, DEFAULT_GAMMA)
+}
+
+// validateLevels ensures that the `blackPoint` and `whitePoint` attributes of f are valid.
+// Returns error if validation fails, nil otherwise.
+// If black / white point is unset, update black / white point attribute to take on default value, return nil.
+// If black / white point is set, and both are validated, return nil.
+// If black / white point is set, but one or both is not validated, or black point is not less than white point, return error.
+func validateLevels(f *FormData) error {
+	errs := ValidationError{}.
+		Add(validateFloatRange(&f.BlackPoint, FORM_BLACK_POINT_NAME, MIN_LEVEL, MAX_LEVEL, DEFAULT_BLACK_POINT)).
+		Add(validateFloatRange(&f.WhitePoint, FORM_WHITE_POINT_NAME, MIN_LEVEL, MAX_LEVEL, DEFAULT_WHITE_POINT))
+
+	if len(errs) == 0 && *f.BlackPoint >= *f.WhitePoint {
+		return FieldError{
+			Field:   FORM_BLACK_POINT_NAME,
+			Code:    ERROR_CONFLICT,
+			Message: "invalid levels: black point must be less than white point",
+			Value:   *f.BlackPoint,
+			Max:     *f.WhitePoint,
+		}
+	}
+
+	return errs.Err()
+}
+
+// validateEqualize ensures that the `equalize` attribute of f is valid.
+// Returns error if validation fails, nil otherwise.
+// If equalize is unset, update equalize attribute to take on default value, return nil.
+// If equalize is set, and validated, return nil.
+// If equalize is set, but not validated, return error.
+func validateEqualize(f *FormData) error {
+	if f.Equalize != nil {
+		equalizations := getEqualizations()
+		if !slices.Contains(equalizations, *f.Equalize) {
+			return newOptionError(FORM_EQUALIZE_NAME, *f.Equalize, equalizations)
+		}
+	} else {
+		defaultVal := DEFAULT_EQUALIZE
+		f.Equalize = &defaultVal
+	}
+
+	return nil
+}
+
+// validateTileSizeAndClipLimit ensures that the `tileSize` and `clipLimit` attributes of f are valid.
+// Returns error if validation fails, nil otherwise.
+// If tile size / clip limit is unset, update tile size / clip limit attribute to take on default value, return nil.
+// If tile size / clip limit is set, and both are validated, return nil.
+// If tile size / clip limit is set, but one or both is not validated, return error.
+func validateTileSizeAndClipLimit(f *FormData) error {
+	errs := ValidationError{}
+	if f.TileSize == nil {
+		tileSize := DEFAULT_TILE_SIZE
+		f.TileSize = &tileSize
+	} else if *f.TileSize < MIN_TILE_SIZE || *f.TileSize > MAX_TILE_SIZE {
+		errs = errs.Add(newRangeError(FORM_TILE_SIZE_NAME, *f.TileSize, MIN_TILE_SIZE, MAX_TILE_SIZE))
+	}
+
+	return errs.Add(validateFloatRange(&f.ClipLimit, FORM_CLIP_LIMIT_NAME, MIN_CLIP_LIMIT, MAX_CLIP_LIMIT, DEFAULT_CLIP_LIMIT)).Err()
+}
+
+// validateEdgeMethod ensures that the `edgeMethod` attribute of f is valid.
+// Returns error if validation fails, nil otherwise.
+// If edge method is unset, update edge method attribute to take on default value, return nil.
+// If edge method is set, and validated, return nil.
+// If edge method is set, but not validated, return error.
+func validateEdgeMethod(f *FormData) error {
+	if f.EdgeMethod != nil {
+		edgeMethods := getEdgeMethods()
+		if !slices.Contains(edgeMethods, *f.EdgeMethod) {
+			return newOptionError(FORM_EDGE_METHOD_NAME, *f.EdgeMethod, edgeMethods)
+		}
+	} else {
+		defaultVal := DEFAULT_EDGE_METHOD
+		f.EdgeMethod = &defaultVal
+	}
+
+	return nil
+}
+
+// validateEdgeThresholds ensures that the `edgeLow` and `edgeHigh` attributes of f are valid.
+// Returns error if validation fails, nil otherwise.
+// If low / high threshold is unset, update low / high threshold attribute to take on default value, return nil.
+// If low / high threshold is set, and both are validated, return nil.
+// If low / high threshold is set, but one or both is not validated, or low threshold exceeds high threshold, return error.
+func validateEdgeThresholds(f *FormData) error {
+	errs := ValidationError{}.
+		Add(validateFloatRange(&f.EdgeLow, FORM_EDGE_LOW_NAME, MIN_EDGE, MAX_EDGE, DEFAULT_EDGE_LOW)).
+		Add(validateFloatRange(&f.EdgeHigh, FORM_EDGE_HIGH_NAME, MIN_EDGE, MAX_EDGE, DEFAULT_EDGE_HIGH))
+
+	if len(errs) == 0 && *f.EdgeLow > *f.EdgeHigh {
+		return FieldError{
+			Field:   FORM_EDGE_LOW_NAME,
+			Code:    ERROR_CONFLICT,
+			Message: "invalid edge thresholds: low threshold must not exceed high threshold",
+			Value:   *f.EdgeLow,
+			Max:     *f.EdgeHigh,
+		}
+	}
+
+	return errs.Err()
+}
+
+// validateBlur ensures that the `blur` attribute of f is valid.
+// Returns error if validation fails, nil otherwise.
+// If blur is unset, update blur attribute to take on default value, return nil.
+// If blur is set, and validated, return nil.
+// If blur is set, but not validated, return error.
+func validateBlur(f *FormData) error {
+	return validateFloatRange(&f.Blur, FORM_BLUR_NAME, MIN_BLUR, MAX_BLUR, DEFAULT_BLUR)
+}
+
+// validateFill ensures that the `fill` attribute of f is valid.
+// Returns error if validation fails, nil otherwise.
+// If fill is unset, update fill attribute to take on default value, return nil.
+// If fill is set, and validated, return nil.
+// If fill is set, but not validated, return error.
+func validateFill(f *FormData) error {
+	return validateFloatRange(&f.Fill, FORM_FILL_NAME, MIN_FILL, MAX_FILL, DEFAULT_FILL)
+}
+
+// validateSharpen ensures that the `sharpenRadius`, `sharpenAmount` and `sharpenThreshold` attributes of f are valid.
+// Returns error if validation fails, nil otherwise.
+// If radius / amount / threshold is unset, update radius / amount / threshold attribute to take on default value, return nil.
+// If radius / amount / threshold is set, and all are validated, return nil.
+// If radius / amount / threshold is set, but at least one is not validated, return error.
+func validateSharpen(f *FormData) error {
+	return ValidationError{}.
+		Add(validateFloatRange(&f.SharpenRadius, FORM_SHARPEN_RADIUS_NAME, MIN_SHARPEN_RADIUS, MAX_SHARPEN_RADIUS, DEFAULT_SHARPEN_RADIUS)).
+		Add(validateFloatRange(&f.SharpenAmount, FORM_SHARPEN_AMOUNT_NAME, MIN_SHARPEN_AMOUNT, MAX_SHARPEN_AMOUNT, DEFAULT_SHARPEN_AMOUNT)).
+		Add(validateFloatRange(&f.SharpenThreshold, FORM_SHARPEN_THRESHOLD_NAME, MIN_SHARPEN_THRESHOLD, MAX_SHARPEN_THRESHOLD, DEFAULT_SHARPEN_THRESHOLD)).
+		Err()
+}
+
+// validateCellSizeAndAngle ensures that the `cellSize` and `angle` attributes of f are valid.
+// Returns error if validation fails, nil otherwise.
+// If cell size / angle is unset, update cell size / angle attribute to take on default value, return nil.
+// If cell size / angle is set, and both are validated, return nil.
+// If cell size / angle is set, but one or both is not validated, return error.
+func validateCellSizeAndAngle(f *FormData) error {
+	errs := ValidationError{}
+	if f.CellSize == nil {
+		cellSize := DEFAULT_CELL_SIZE
+		f.CellSize = &cellSize
+	} else if *f.CellSize < MIN_CELL_SIZE || *f.CellSize > MAX_CELL_SIZE {
+		errs = errs.Add(newRangeError(FORM_CELL_SIZE_NAME, *f.CellSize, MIN_CELL_SIZE, MAX_CELL_SIZE))
+	}
+
+	return errs.Add(validateFloatRange(&f.Angle, FORM_ANGLE_NAME, MIN_ANGLE, MAX_ANGLE, DEFAULT_ANGLE)).Err()
+}
+
+// validateKernelNode ensures that a single node of a dither kernel is valid.
+// A node must be "forward" in scan order (to the right on the same row, or on a later row), within `MAX_KERNEL_OFFSET` pixels
+// of the current pixel, and have a finite, non-negative weight.
+// Returns error if validation fails, nil otherwise.
+func validateKernelNode(node KernelNode) error {
+	if node.Dy < 0 || (node.Dy == 0 && node.Dx <= 0) {
+		return newKernelError(ERROR_INVALID_KERNEL, node, "node (%d, %d) must come after the current pixel in scan order", node.Dx, node.Dy)
+	}
+
+	if node.Dy > MAX_KERNEL_OFFSET || node.Dx < -MAX_KERNEL_OFFSET || node.Dx > MAX_KERNEL_OFFSET {
+		return newKernelError(ERROR_INVALID_KERNEL, node, "node (%d, %d) must be within %d pixels of the current pixel", node.Dx, node.Dy, MAX_KERNEL_OFFSET)
+	}
+
+	if node.Weight < 0 || math.IsNaN(node.Weight) || math.IsInf(node.Weight, 0) {
+		return newKernelError(ERROR_INVALID_KERNEL, node, "node (%d, %d) must have a non-negative weight", node.Dx, node.Dy)
+	}
+
+	return nil
+}
+
+// validateKernel ensures that the `kernel` attribute of f is valid.
+// Returns error if validation fails, nil otherwise.
+// If kernel is unset, and style is not custom, return nil.
+// If kernel is set, style is custom, and kernel is validated, return nil.
+// If kernel is set, but style is not custom, or kernel is not validated, return error.
+// If kernel is unset, but style is custom, return error.
+// Must be called after validateStyle.
+func validateKernel(f *FormData) error {
+	if *f.Style != STYLE_CUSTOM {
+		if f.Kernel != nil {
+			return newKernelError(ERROR_CONFLICT, nil, "can only be used with the %s style", STYLE_CUSTOM)
+		}
+		return nil
+	}
+
+	if f.Kernel == nil {
+		return newKernelError(ERROR_REQUIRED, nil, "required when using the %s style", STYLE_CUSTOM)
+	}
+
+	kernel := *f.Kernel
+	if len(kernel.Nodes) < 1 || len(kernel.Nodes) > MAX_KERNEL_NODES {
+		return newKernelError(ERROR_INVALID_KERNEL, len(kernel.Nodes), "must have between 1 and %d nodes", MAX_KERNEL_NODES)
+	}
+
+	if !(kernel.Divisor > 0) || math.IsInf(kernel.Divisor, 0) {
+		return newKernelError(ERROR_INVALID_KERNEL, kernel.Divisor, "divisor must be a positive number")
+	}
+
+	positions := []RelativePosition{}
+	totalWeight := 0.0
+	for _, node := range kernel.Nodes {
+		if err := validateKernelNode(node); err != nil {
+			return err
+		}
+
+		position := RelativePosition{Dx: node.Dx, Dy: node.Dy}
+		if slices.Contains(positions, position) {
+			return newKernelError(ERROR_INVALID_KERNEL, node, "node (%d, %d) is defined more than once", node.Dx, node.Dy)
+		}
+		positions = append(positions, position)
+		totalWeight += node.Weight
+	}
+
+	if totalWeight <= 0 || totalWeight > kernel.Divisor {
+		return newKernelError(ERROR_INVALID_KERNEL, totalWeight, "sum of weights must be positive, and cannot exceed divisor")
+	}
+
+	return nil
+}
+
+// validateDiffusion ensures that the `diffusion` attribute of f is valid.
+// Returns error if validation fails, nil otherwise.
+// If diffusion is unset, update diffusion attribute to take on default value, return nil.
+// If diffusion is set, and validated, return nil.
+// If diffusion is set, but not validated, return error.
+func validateDiffusion(f *FormData) error {
+	return validateFloatRange(&f.Diffusion, FORM_DIFFUSION_NAME, MIN_DIFFUSION, MAX_DIFFUSION, DEFAULT_DIFFUSION)
+}
+
+// validateClamp ensures that the `clamp_min` and `clamp_max` attributes of f are valid.
+// Returns error if validation fails, nil otherwise.
+// If clamp min / max is unset, update clamp min / max attribute to take on default value, return nil.
+// If clamp min / max is set, and both are validated, return nil.
+// If clamp min / max is set, but one or both is not validated, or clamp min is greater than clamp max, return error.
+func validateClamp(f *FormData) error {
+	errs := ValidationError{}.
+		Add(validateFloatRange(&f.ClampMin, FORM_CLAMP_MIN_NAME, MIN_CLAMP, MAX_CLAMP, DEFAULT_CLAMP_MIN)).
+		Add(validateFloatRange(&f.ClampMax, FORM_CLAMP_MAX_NAME, MIN_CLAMP, MAX_CLAMP, DEFAULT_CLAMP_MAX))
+
+	if len(errs) == 0 && *f.ClampMin > *f.ClampMax {
+		return FieldError{
+			Field:   FORM_CLAMP_MIN_NAME,
+			Code:    ERROR_CONFLICT,
+			Message: "invalid clamp: clamp min cannot be greater than clamp max",
+			Value:   *f.ClampMin,
+			Max:     *f.ClampMax,
+		}
+	}
+
+	return errs.Err()
+}
+
+// validateFormat ensures that the `format` attribute of f is valid.
+// Returns error if validation fails, nil otherwise.
+// If format is unset, update format attribute to take on default value, return nil.
+// If format is set, and validated, return nil.
+// If format is set, but not validated, return error.
+func validateFormat(f *FormData) error {
+	if f.Format != nil {
+		formats := getFormats()
+		if !slices.Contains(formats, *f.Format) {
+			return newOptionError(FORM_FORMAT_NAME, *f.Format, formats)
+		}
+	} else {
+		defaultVal := DEFAULT_FORMAT
+		f.Format = &defaultVal
+	}
+
+	return nil
+}
+
 // validateWidthAndHeight ensures that the `width` and `height` attributes of f are valid.
 // Returns error if validation fails, nil otherwise.
 // If width / height is unset, update width / height attribute to take on default value, return nil.
 // If width / height is set, and both are validated, return nil.
 // If width / height is set, but one or both is not validated, return error.
 func validateWidthAndHeight(f *FormData, bounds image.Rectangle) error {
-	widthErrMsg := fmt.Sprintf("invalid width: must be a number between %d and %d", MIN_LENGTH, MAX_LENGTH)
-	heightErrMsg := fmt.Sprintf("invalid height: must be a number between %d and %d", MIN_LENGTH, MAX_LENGTH)
-	errs := []string{}
+	errs := ValidationError{}
 
 	if f.Width == nil {
-		widthVal := DEFAULT_WIDTH
+		widthVal := config.DefaultWidth
 		f.Width = &widthVal
-	} else if *f.Width < MIN_LENGTH || *f.Width > MAX_LENGTH {
-		errs = append(errs, widthErrMsg)
+	} else if *f.Width < MIN_LENGTH || *f.Width > config.MaxLength {
+		errs = errs.Add(newRangeError(FORM_WIDTH_NAME, *f.Width, MIN_LENGTH, config.MaxLength))
 	}
 
 	if f.Height == nil {
 		imgWidth, imgHeight := bounds.Max.X-bounds.Min.X, bounds.Max.Y-bounds.Min.Y
 		calculatedHeight := int(math.Round(float64(*f.Width*imgHeight) / float64(imgWidth) / 2.0))
-		calculatedHeight = min(calculatedHeight, MAX_LENGTH)
+		calculatedHeight = min(calculatedHeight, config.MaxLength)
 		f.Height = &calculatedHeight
-	} else if *f.Height < MIN_LENGTH || *f.Height > MAX_LENGTH {
-		errs = append(errs, heightErrMsg)
+	} else if *f.Height < MIN_LENGTH || *f.Height > config.MaxLength {
+		errs = errs.Add(newRangeError(FORM_HEIGHT_NAME, *f.Height, MIN_LENGTH, config.MaxLength))
 	}
 
-	var err error
-	if len(errs) > 0 {
-		err = errors.New(strings.Join(errs, ", "))
-	}
-
-	return err
+	return errs.Err()
 }
 
 // validateStyle ensures that the `style` attribute of f is valid.
@@ -213,10 +989,10 @@ func validateStyle(f *FormData) error {
 	if f.Style != nil {
 		style := *f.Style
 		if !slices.Contains(getStyles(), style) {
-			return getInvalidStylesError()
+			return newOptionError(FORM_STYLE_NAME, style, getStyles())
 		}
 	} else {
-		defaultVal := DEFAULT_STYLE
+		defaultVal := config.DefaultStyle
 		f.Style = &defaultVal
 	}
 
@@ -228,7 +1004,7 @@ func validateStyle(f *FormData) error {
 // However, if style does not use dithering, returns an empty slice of DitherNodes.
 func getDither(style string) []DitherNode {
 	switch style {
-	case STYLE_NORMAL:
+	case STYLE_NORMAL, STYLE_LINE_ART:
 		return []DitherNode{
 			// Floyd-Steinberg [https://en.wikipedia.org/wiki/Floyd%E2%80%93Steinberg_dithering]
 			{value: 7.0 / 16.0, RelativePosition: RelativePosition{Dx: 1, Dy: 0}},
@@ -273,13 +1049,28 @@ func getDither(style string) []DitherNode {
 	return []DitherNode{}
 }
 
-// getEncodingSettings returns the encoding settings associated with an encoding style.
+// getCustomDither converts a user-defined dither kernel into a slice of DitherNodes.
+// Kernel is expected to be validated before calling this function.
+func getCustomDither(kernel DitherKernel) []DitherNode {
+	dither := make([]DitherNode, len(kernel.Nodes))
+	for i, node := range kernel.Nodes {
+		dither[i] = DitherNode{
+			value:            node.Weight / kernel.Divisor,
+			RelativePosition: RelativePosition{Dx: node.Dx, Dy: node.Dy},
+		}
+	}
+	return dither
+}
+
+// getEncodingSettings returns the encoding settings associated with the encoding style of form.
 // Generally this function returns EncodingSettings struct with a `nil` error.
 // If style has no encoding setting, we define error in our return.
-func getEncodingSettings(style string) (EncodingSettings, error) {
+// Form is expected to be validated before calling this function.
+func getEncodingSettings(form FormData) (EncodingSettings, error) {
 	var encodingSettings EncodingSettings
 	var err error
 	isValidStyle := true
+	style := *form.Style
 
 	switch style {
 	case STYLE_NORMAL:
@@ -307,6 +1098,29 @@ func getEncodingSettings(style string) (EncodingSettings, error) {
 			DitherNodes:            getDither(style),
 			UsePercievedBrightness: false,
 		}
+	case STYLE_LINE_ART:
+		encodingSettings = EncodingSettings{
+			DitherNodes:            getDither(style),
+			UsePercievedBrightness: false,
+			DetectEdges:            true,
+		}
+	case STYLE_BLUE_NOISE:
+		encodingSettings = EncodingSettings{
+			DitherNodes:            getDither(style),
+			UsePercievedBrightness: false,
+			ThresholdMap:           getBlueNoiseThreshold,
+		}
+	case STYLE_HALFTONE:
+		encodingSettings = EncodingSettings{
+			DitherNodes:            getDither(style),
+			UsePercievedBrightness: false,
+			ThresholdMap:           getHalftoneThresholdMap(*form.CellSize, *form.Angle),
+		}
+	case STYLE_CUSTOM:
+		encodingSettings = EncodingSettings{
+			DitherNodes:            getCustomDither(*form.Kernel),
+			UsePercievedBrightness: false,
+		}
 	default:
 		isValidStyle = false
 	}
@@ -315,12 +1129,35 @@ func getEncodingSettings(style string) (EncodingSettings, error) {
 		err = getInvalidStylesError()
 	}
 
+	encodingSettings.DiffusionStrength = *form.Diffusion / MAX_DIFFUSION
+	encodingSettings.IsClamped = form.IsClamp.Bool()
+	encodingSettings.ClampMin = *form.ClampMin / MAX_CLAMP
+	encodingSettings.ClampMax = *form.ClampMax / MAX_CLAMP
+
 	return encodingSettings, err
 }
 
-// getLinearizedChannel takes a standard, 8-bit color channel, and converts it to a linearized value between 0.0 and 1.0.
-// For more information, see: https://en.wikipedia.org/wiki/SRGB#Transfer_function_(%22gamma%22)
+// linearizedChannels maps each 8-bit color channel to it's linearized value, so that math.Pow is evaluated once per channel
+// value, rather than once per pixel.
+var linearizedChannels = getLinearizationTable()
+
+// getLinearizationTable returns the linearized value of every 8-bit color channel, indexed by the channel.
+func getLinearizationTable() [256]float64 {
+	var table [256]float64
+	for i := range table {
+		table[i] = linearizeChannel(uint8(i))
+	}
+	return table
+}
+
+// getLinearizedChannel takes a standard, 8-bit color channel, and returns it's linearized value between 0.0 and 1.0.
 func getLinearizedChannel(colorChannel uint8) float64 {
+	return linearizedChannels[colorChannel]
+}
+
+// linearizeChannel takes a standard, 8-bit color channel, and converts it to a linearized value between 0.0 and 1.0.
+// For more information, see: https://en.wikipedia.org/wiki/SRGB#Transfer_function_(%22gamma%22)
+func linearizeChannel(colorChannel uint8) float64 {
 	v := float64(colorChannel) / 255.0
 
 	if v <= 0.04045 {
@@ -337,20 +1174,15 @@ func getLuminance(r float64, g float64, b float64) float64 {
 	return (0.2126 * r) + (0.7152 * g) + (0.0722 * b)
 }
 
-// getColor takes a full 32-bit color channel, and an opacity value between 0.0 and 1.0, and converts the color to the 8-bit
+// getColor takes an 8-bit color channel, and an opacity value between 0.0 and 1.0, and converts the color to the 8-bit
 // representation with opaicty "applied" such that the full color can be represented as RGB without A.
-func getColor(channel uint32, opacity float64) uint8 {
-	color := uint8(channel >> 8)
+func getColor(color uint8, opacity float64) uint8 {
 	return uint8(math.Round(255.0 - opacity*float64(255-color)))
 }
 
-// getPixelLuminance takes a pixel, and returns it's luminance.
-// At a high level, this converts a full-color pixel to a black-and-white value, represented as a number between 0.0 and 1.0.
-func getPixelLuminance(pixel color.Color) float64 {
-	r, g, b, a := pixel.RGBA()
-
-	// convert to 8-bit value
-	opacity := float64(uint8(a>>8) / 255.0)
+// getChannelLuminance takes the 8-bit channels of a pixel, and returns it's luminance.
+func getChannelLuminance(r, g, b, a uint8) float64 {
+	opacity := float64(a / 255.0)
 	red := getColor(r, opacity)
 	green := getColor(g, opacity)
 	blue := getColor(b, opacity)
@@ -360,6 +1192,66 @@ func getPixelLuminance(pixel color.Color) float64 {
 	return getLuminance(lr, lg, lb)
 }
 
+// getRGBALuminance takes the full 32-bit channels of a pixel, as returned by color.Color's RGBA method, and returns it's
+// luminance.
+func getRGBALuminance(r, g, b, a uint32) float64 {
+	// convert to 8-bit value
+	return getChannelLuminance(uint8(r>>8), uint8(g>>8), uint8(b>>8), uint8(a>>8))
+}
+
+// getPixelLuminance takes a pixel, and returns it's luminance.
+// At a high level, this converts a full-color pixel to a black-and-white value, represented as a number between 0.0 and 1.0.
+func getPixelLuminance(pixel color.Color) float64 {
+	return getRGBALuminance(pixel.RGBA())
+}
+
+// getLuminanceSampler returns a function that returns the luminance of the pixel of img at (x, y), equal to
+// getPixelLuminance(img.At(x, y)). The image types produced by the PNG & JPEG decoders are read directly from their pixel
+// buffers, which avoids the interface call & allocation of img.At for every pixel. Any other type, or any point outside of the
+// image, falls back to img.At.
+func getLuminanceSampler(img image.Image) func(x, y int) float64 {
+	bounds := img.Bounds()
+	withFallback := func(sample func(x, y int) float64) func(x, y int) float64 {
+		return func(x, y int) float64 {
+			if !(image.Point{X: x, Y: y}.In(bounds)) {
+				return getPixelLuminance(img.At(x, y))
+			}
+			return sample(x, y)
+		}
+	}
+
+	switch img := img.(type) {
+	case *image.YCbCr:
+		return withFallback(func(x, y int) float64 {
+			return getRGBALuminance(img.YCbCrAt(x, y).RGBA())
+		})
+	case *image.RGBA:
+		return withFallback(func(x, y int) float64 {
+			i := img.PixOffset(x, y)
+			pixel := img.Pix[i : i+4 : i+4]
+			return getChannelLuminance(pixel[0], pixel[1], pixel[2], pixel[3])
+		})
+	case *image.NRGBA:
+		return withFallback(func(x, y int) float64 {
+			i := img.PixOffset(x, y)
+			pixel := img.Pix[i : i+4 : i+4]
+			return getRGBALuminance(color.NRGBA{R: pixel[0], G: pixel[1], B: pixel[2], A: pixel[3]}.RGBA())
+		})
+	case *image.Paletted:
+		luminances := make([]float64, len(img.Palette))
+		for i, pixel := range img.Palette {
+			luminances[i] = getPixelLuminance(pixel)
+		}
+		return withFallback(func(x, y int) float64 {
+			return luminances[img.Pix[img.PixOffset(x, y)]]
+		})
+	default:
+		return func(x, y int) float64 {
+			return getPixelLuminance(img.At(x, y))
+		}
+	}
+}
+
 // getPercievedLuminance takes a luminance value, and returns it's percieved brightness.
 // For more information, see: https://en.wikipedia.org/wiki/Lightness#1976
 func getPercievedBrightness(luminance float64) float64 {
@@ -393,58 +1285,213 @@ func getFormData(c *gin.Context) (FormData, error) {
 	return form, nil
 }
 
-// validateFormData validates each form field that requires it.
-// If all validation tests pass, then this function will simply return nil.
-// If at least one validation test fails, then return an error with more details.
-func validateFormData(form *FormData, bounds image.Rectangle) error {
-	if err := validateTheme(form); err != nil {
-		return err
+// getBase64Encodings returns the base64 encodings accepted for an image, in the order they are tried: standard or URL-safe
+// alphabets, with or without padding.
+func getBase64Encodings() []*base64.Encoding {
+	return []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding}
+}
+
+// decodeBase64Image takes a base64-encoded image, optionally formatted as a data URL (data:image/png;base64,...), and returns
+// the bytes of the image. Each encoding in getBase64Encodings is tried in turn.
+// Returns an error if the image is missing, or is not valid base64.
+func decodeBase64Image(encoded string) ([]byte, error) {
+	if encoded == "" {
+		return nil, errors.New("no image provided")
+	}
+	if strings.HasPrefix(encoded, "data:") {
+		if _, data, found := strings.Cut(encoded, ","); found {
+			encoded = data
+		}
 	}
 
-	if err := validateExposure(form); err != nil {
-		return err
+	for _, encoding := range getBase64Encodings() {
+		if data, err := encoding.DecodeString(encoded); err == nil {
+			return data, nil
+		}
 	}
 
-	if err := validateWidthAndHeight(form, bounds); err != nil {
-		return err
+	return nil, errors.New("bad image encoding: must be base64")
+}
+
+// getImageBounds reads the header of an image, and returns it's bounds, without decoding the pixels.
+// Returns an error if the image is not a supported image format.
+func getImageBounds(data []byte) (image.Rectangle, error) {
+	imageConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return image.Rectangle{}, errors.New("bad image format: must be either png or jpg/jpeg")
 	}
+	return image.Rect(0, 0, imageConfig.Width, imageConfig.Height), nil
+}
 
-	if err := validateStyle(form); err != nil {
-		return err
+// decodeImage decodes the bytes of an image.
+// Returns an error if the image is not a supported image format, or is malformed.
+func decodeImage(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("bad image format: must be either png or jpg/jpeg")
 	}
+	return img, nil
+}
 
-	return nil
+// getMultipartData takes a gin context with a multipart form body, and returns the bytes of the image and form data.
+// Returns an error if the image is missing, or the form data is malformed. If a form field cannot be parsed, the error is a
+// ValidationError.
+func getMultipartData(c *gin.Context) ([]byte, FormData, error) {
+	file, _, err := c.Request.FormFile(FORM_IMAGE_NAME)
+	if isBodyTooLarge(err) {
+		return nil, FormData{}, err
+	}
+	if err != nil {
+		return nil, FormData{}, errors.New("no image provided")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil || len(data) == 0 {
+		return nil, FormData{}, errors.New("no image provided")
+	}
+
+	form, err := getFormData(c)
+	if err != nil {
+		return nil, form, getBindingError(err, getFormTypeErrors(c.Request.MultipartForm.Value))
+	}
+	return data, form, nil
+}
+
+// getJSONData takes a gin context with a JSON body, and returns the bytes of the image and form data.
+// Returns an error if the body is malformed, or the image is missing or not valid base64. If a field cannot be parsed, the
+// error is a ValidationError.
+func getJSONData(c *gin.Context) ([]byte, FormData, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, FormData{}, err
+	}
+
+	var request JSONRequest
+	if err := binding.JSON.BindBody(body, &request); err != nil {
+		return nil, FormData{}, getBindingError(err, getJSONTypeErrors(body))
+	}
+
+	data, err := decodeBase64Image(request.Image)
+	return data, request.FormData, err
+}
+
+// getRawImageData takes a gin context whose body is the raw bytes of an image, and returns the bytes of the image, along with
+// form data parsed from the query string.
+// Returns an error if the image is missing, or the query string is malformed. If a query parameter cannot be parsed, the error
+// is a ValidationError.
+func getRawImageData(c *gin.Context) ([]byte, FormData, error) {
+	var form FormData
+	if err := c.ShouldBindQuery(&form); err != nil {
+		return nil, form, getBindingError(err, getFormTypeErrors(c.Request.URL.Query()))
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if isBodyTooLarge(err) {
+		return nil, form, err
+	}
+	if err != nil || len(data) == 0 {
+		return nil, form, errors.New("no image provided")
+	}
+
+	return data, form, nil
+}
+
+// negotiateFormat determines the response format of a request, if the request body did not define one.
+// The `format` query parameter takes precedence, followed by the `Accept` header. If neither picks a format, the format is
+// left unset, so that validation can apply the default.
+func negotiateFormat(c *gin.Context, form *FormData) {
+	if form.Format != nil {
+		return
+	}
+
+	if format, ok := c.GetQuery(FORM_FORMAT_NAME); ok {
+		form.Format = &format
+		return
+	}
+
+	if c.GetHeader("Accept") == "" {
+		return
+	}
+	switch c.NegotiateFormat(binding.MIMEJSON, binding.MIMEPlain, MIME_DETAILED) {
+	case binding.MIMEJSON:
+		format := FORMAT_JSON
+		form.Format = &format
+	case binding.MIMEPlain:
+		format := FORMAT_TEXT
+		form.Format = &format
+	case MIME_DETAILED:
+		format := FORMAT_DETAILED
+		form.Format = &format
+	}
+}
+
+// validateFormData validates each form field that requires it.
+// If all validation tests pass, then this function will simply return nil.
+// If at least one validation test fails, then return a ValidationError, describing every field that failed.
+func validateFormData(form *FormData, bounds image.Rectangle) error {
+	return ValidationError{}.
+		Add(validateTheme(form)).
+		Add(validateExposure(form)).
+		Add(validateWidthAndHeight(form, bounds)).
+		Add(validateStyle(form)).
+		Add(validateBrightness(form)).
+		Add(validateContrast(form)).
+		Add(validateGamma(form)).
+		Add(validateLevels(form)).
+		Add(validateEqualize(form)).
+		Add(validateTileSizeAndClipLimit(form)).
+		Add(validateEdgeMethod(form)).
+		Add(validateEdgeThresholds(form)).
+		Add(validateBlur(form)).
+		Add(validateFill(form)).
+		Add(validateSharpen(form)).
+		Add(validateCellSizeAndAngle(form)).
+		Add(validateKernel(form)).
+		Add(validateDiffusion(form)).
+		Add(validateClamp(form)).
+		Add(validateFormat(form)).
+		Err()
 }
 
 // getGrayscaleMatrix takes an image, and returns it in a grayscaled matrix format, with dimensions `totalHeight` x `totalWidth`.
 // Each element in the matrix represents a pixel, converted to grayscale (luminance).
-// Note that grayscale[y][x] does not correspond to image.At(x, y), since the width and height of the image may not correspond
+// Note that grayscale.At(x, y) does not correspond to image.At(x, y), since the width and height of the image may not correspond
 // to `totalWidth` & `totalHeight`.
-func getGrayscaleMatrix(img image.Image, totalWidth, totalHeight int) [][]float64 {
+// The matrix is taken from the buffer pool, so it should be released once it is no longer used.
+func getGrayscaleMatrix(img image.Image, totalWidth, totalHeight int) *LuminanceBuffer {
 	bounds := img.Bounds()
 	imageWidth, imageHeight := bounds.Max.X-bounds.Min.X, bounds.Max.Y-bounds.Min.Y
 	scaleX, scaleY := float64(totalWidth)/float64(imageWidth), float64(totalHeight)/float64(imageHeight)
 
-	getOriginalCoords := func(x, y int) (int, int) {
-		originalX, originalY := float64(x)/float64(scaleX), float64(y)/float64(scaleY)
-		return int(math.Round(originalX)), int(math.Round(originalY))
+	getOriginalCoord := func(n int, scale float64) int {
+		return int(math.Round(float64(n) / scale))
 	}
 
-	grayscale := make([][]float64, totalHeight)
-	for y := range grayscale {
-		grayscale[y] = make([]float64, totalWidth)
-		for x := range grayscale[y] {
-			originalX, originalY := getOriginalCoords(x, y)
-			grayscale[y][x] = getPixelLuminance(img.At(originalX, originalY))
-		}
+	// every row samples the same columns, so they are only computed once
+	originalXs := make([]int, totalWidth)
+	for x := range originalXs {
+		originalXs[x] = getOriginalCoord(x, scaleX)
 	}
 
+	sample := getLuminanceSampler(img)
+	grayscale := getLuminanceBuffer(totalWidth, totalHeight)
+	parallelRows(totalHeight, func(y int) {
+		originalY := getOriginalCoord(y, scaleY)
+		row := grayscale.Row(y)
+		for x, originalX := range originalXs {
+			row[x] = float32(sample(originalX, originalY))
+		}
+	})
+
 	return grayscale
 }
 
 // diffuseError performs the error diffusion operation of a dithering algorithm.
+// The error is scaled by the diffusion strength of the encoding settings, and if clamping is enabled, each updated value is
+// clamped between the clamp bounds, preventing error from accumulating into long streaks after extreme regions.
 // For more information, see: [https://en.wikipedia.org/wiki/Error_diffusion]
-func diffuseError(dither []DitherNode, grayscaleMatrix [][]float64, point Point, quantError float64) {
+func diffuseError(encodingSettings EncodingSettings, grayscaleMatrix *LuminanceBuffer, point Point, quantError float64) {
 	compare := func(n, dn, length int) bool {
 		if dn < n {
 			return dn > 0
@@ -452,11 +1499,16 @@ func diffuseError(dither []DitherNode, grayscaleMatrix [][]float64, point Point,
 		return dn < length
 	}
 
-	width, height := len(grayscaleMatrix[point.Y]), len(grayscaleMatrix)
-	for _, node := range dither {
+	width, height := grayscaleMatrix.Width, grayscaleMatrix.Height
+	scaledError := quantError * encodingSettings.DiffusionStrength
+	for _, node := range encodingSettings.DitherNodes {
 		dx, dy := point.X+node.RelativePosition.Dx, point.Y+node.RelativePosition.Dy
 		if compare(point.X, dx, width) && compare(point.Y, dy, height) {
-			grayscaleMatrix[dy][dx] = grayscaleMatrix[dy][dx] + quantError*node.value
+			value := float64(grayscaleMatrix.At(dx, dy)) + scaledError*node.value
+			if encodingSettings.IsClamped {
+				value = math.Max(encodingSettings.ClampMin, math.Min(encodingSettings.ClampMax, value))
+			}
+			grayscaleMatrix.Set(dx, dy, float32(value))
 		}
 	}
 }
@@ -472,10 +1524,21 @@ func getMaxExposure(exposure float64, usePercievedBrightness bool) float64 {
 	return exposure / 100.0
 }
 
+// getThreshold determines the exposure threshold of the pixel at `point`.
+// Generally, every pixel shares the same threshold, `maxExposure`.
+// However, if the encoding settings define a threshold map, the threshold is offset by the map's value at `point`, such that the
+// default exposure compares each pixel directly against the map.
+func getThreshold(point Point, maxExposure float64, encodingSettings EncodingSettings) float64 {
+	if encodingSettings.ThresholdMap == nil {
+		return maxExposure
+	}
+	return maxExposure + encodingSettings.ThresholdMap(point) - 0.5
+}
+
 // pixelsToAscii converts a set of 8 pixels, starting at `point` and forming a brail shape (⣿), into an ASCII character,
 // by analysing each pixel invididually, based on the exposure of each pixel.
 // This function will diffuse the error generated by each pixel on every iteration.
-func pixelsToAscii(point Point, form FormData, grayscaleMatrix [][]float64, encodingSettings EncodingSettings) rune {
+func pixelsToAscii(point Point, form FormData, grayscaleMatrix *LuminanceBuffer, encodingSettings EncodingSettings) rune {
 	var offset uint8 = 0
 	transformedX, transformedY := point.X*CHAR_WIDTH, point.Y*CHAR_HEIGHT
 	maxExposure := getMaxExposure(*form.Exposure, encodingSettings.UsePercievedBrightness)
@@ -483,19 +1546,19 @@ func pixelsToAscii(point Point, form FormData, grayscaleMatrix [][]float64, enco
 	for dy := 0; dy < int(CHAR_HEIGHT); dy++ {
 		for dx := 0; dx < int(CHAR_WIDTH); dx++ {
 			x, y := transformedX+dx, transformedY+dy
-			exposure := grayscaleMatrix[y][x]
+			exposure := float64(grayscaleMatrix.At(x, y))
 			if encodingSettings.UsePercievedBrightness {
 				exposure = getPercievedBrightness(exposure)
 			}
 
 			quantError := exposure
-			if exposure < maxExposure {
+			if exposure < getThreshold(Point{X: x, Y: y}, maxExposure, encodingSettings) {
 				offset |= (1 << getPixelNumber(dx, dy))
 			} else {
 				quantError -= 1.0
 			}
 
-			diffuseError(encodingSettings.DitherNodes, grayscaleMatrix, Point{X: x, Y: y}, quantError)
+			diffuseError(encodingSettings, grayscaleMatrix, Point{X: x, Y: y}, quantError)
 		}
 	}
 
@@ -514,128 +1577,324 @@ func invertBrail(r rune) rune {
 	return r ^ 0xFF
 }
 
-// invertAscii loops over the entire ascii, and inverts each brail element.
-// Note that this function generates a copy of the original ascii slice.
-func invertAscii(ascii []string) []string {
-	invertedAscii := make([]string, len(ascii))
-
-	for y, row := range ascii {
-		var builder strings.Builder
-		for _, r := range row {
-			builder.WriteRune(invertBrail(r))
-		}
-		invertedAscii[y] = builder.String()
-	}
-
-	return invertedAscii
-}
-
 // generateAscii takes our input image, as well as configuration settings, and generates an ASCII representation of the image
+// Styles that diffuse error must convert each character in order, since each character depends on the error of the characters
+// before it. Otherwise, rows of characters are independent, so they are converted concurrently.
+// Every character is written to a single buffer, and each row of the result is a slice of it, rather than a separate string.
 func generateAscii(img image.Image, form FormData, encodingSettings EncodingSettings) []string {
-	ascii := []string{}
 	grayscaleMatrix := getGrayscaleMatrix(img, CHAR_WIDTH**form.Width, CHAR_HEIGHT**form.Height)
+	defer releaseLuminanceBuffer(grayscaleMatrix)
+
+	equalizeGrayscaleMatrix(grayscaleMatrix, form)
+	adjustGrayscaleMatrix(grayscaleMatrix, form)
+	sharpenGrayscaleMatrix(grayscaleMatrix, form)
+	if encodingSettings.DetectEdges {
+		drawEdges(grayscaleMatrix, form)
+	}
+
+	width, height := *form.Width, *form.Height
+	rowBytes := width * CHAR_BYTES
+	isInverted := isInvertNeeded(form.IsInvert.Bool(), *form.Theme)
+	text := make([]byte, height*rowBytes)
+	convertRow := func(y int) {
+		offset := y * rowBytes
+		for x := range width {
+			r := pixelsToAscii(Point{X: x, Y: y}, form, grayscaleMatrix, encodingSettings)
+			if isInverted {
+				r = invertBrail(r)
+			}
+			offset += utf8.EncodeRune(text[offset:], r)
+		}
+	}
 
-	for y := 0; y < *form.Height; y++ {
-		var builder strings.Builder
-		for x := 0; x < *form.Width; x++ {
-			builder.WriteRune(pixelsToAscii(Point{X: x, Y: y}, form, grayscaleMatrix, encodingSettings))
+	if len(encodingSettings.DitherNodes) > 0 {
+		for y := range height {
+			convertRow(y)
 		}
-		ascii = append(ascii, builder.String())
+	} else {
+		parallelRows(height, convertRow)
 	}
 
-	if isInvertNeeded(form.IsInvert.Bool(), *form.Theme) {
-		ascii = invertAscii(ascii)
+	rows := string(text)
+	ascii := make([]string, height)
+	for y := range ascii {
+		ascii[y] = rows[y*rowBytes : (y+1)*rowBytes]
 	}
 
 	return ascii
 }
 
+// getDetailedResponse builds the detailed response for a generated ASCII.
+// Form is expected to be validated, so settings reflect the values actually used, including defaults and computed height.
+// Since validation reverses the exposure internally, it is reversed back, so that exposure is reported in the user's scale.
+func getDetailedResponse(ascii []string, form FormData, bounds image.Rectangle, start time.Time) DetailedResponse {
+	settings := form
+	exposure := MAX_EXPOSURE - *form.Exposure
+	settings.Exposure = &exposure
+
+	characters := 0
+	for _, row := range ascii {
+		characters += utf8.RuneCountInString(row)
+	}
+
+	return DetailedResponse{
+		Version:          RESPONSE_VERSION,
+		Ascii:            ascii,
+		Settings:         settings,
+		Source:           ImageDimensions{Width: bounds.Dx(), Height: bounds.Dy()},
+		Characters:       characters,
+		ProcessingTimeMs: getMilliseconds(time.Since(start)),
+	}
+}
+
 // getAscii is the function executed when a user does a POST request to "/".
 // This function parses the request body, and if validated, will generate an ASCII representation of their image.
-// In the event of a success, the server will return a simple JSON object containing an ASCII matrix.
-// In the event of a failure, the server will return an error JSON object to the client.
+// The request body may either be a multipart form, a JSON object with a base64-encoded image, or the raw bytes of an image
+// with options passed in the query string.
+// In the event of a success, the server will return a simple JSON object containing an ASCII matrix, or, if the text format is
+// requested, the rows of the ASCII separated by newlines, or if the detailed format is requested, a versioned JSON object
+// containing the ASCII along with the effective settings and other metadata, sent as the `MIME_DETAILED` media type.
+// Generated ASCIIs are cached by image & settings, and each response is tagged with an ETag. If the client sends a matching
+// If-None-Match header, the server responds with a 304 instead of the ASCII.
+// In the event of a failure, the server will return an error JSON object to the client. If validation fails, the object also
+// includes an `errors` array, describing each invalid field.
+// Request bodies larger than the configured maximum are rejected with a 413, whatever their content type.
 func getAscii(c *gin.Context) {
-	// attempt to open image, and validate it
-	file, _, err := c.Request.FormFile(FORM_IMAGE_NAME)
-	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no image provided"})
+	start := time.Now()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(config.MaxBodySize))
+
+	// read image & form data, based on content type
+	var data []byte
+	var form FormData
+	var err error
+
+	switch c.ContentType() {
+	case binding.MIMEMultipartPOSTForm:
+		data, form, err = getMultipartData(c)
+	case binding.MIMEJSON:
+		data, form, err = getJSONData(c)
+	case MIME_PNG, MIME_JPEG, MIME_JPG, MIME_OCTET_STREAM:
+		data, form, err = getRawImageData(c)
+	default:
+		respondWithError(c, http.StatusUnsupportedMediaType, ErrorResponse{
+			Error: fmt.Sprintf(
+				"unsupported content type: must be one of the following: %s",
+				strings.Join(getContentTypes(), ", "),
+			),
+		})
+		return
+	}
+	if isBodyTooLarge(err) {
+		getLogger(c).Warn("request body too large", slog.String("content_type", c.ContentType()), slog.Int("limit", config.MaxBodySize))
+		tooLargeError := newTooLargeError(int64(config.MaxBodySize))
+		respondWithError(c, http.StatusRequestEntityTooLarge, ErrorResponse{Error: tooLargeError.Message, Errors: []FieldError{tooLargeError}})
 		return
 	}
-	defer file.Close()
-	image, _, err := image.Decode(file)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "bad image format: must be either png or jpg/jpeg"})
+		var validationError ValidationError
+		errors.As(err, &validationError)
+		getLogger(c).Warn("failed to read image", slog.String("content_type", c.ContentType()), slog.Any("error", err))
+		respondWithError(c, http.StatusBadRequest, ErrorResponse{Error: err.Error(), Errors: validationError})
 		return
 	}
 
-	// read form data, and validate it
-	form, err := getFormData(c)
+	// read image dimensions, without decoding the image, since it may not need to be decoded at all
+	bounds, err := getImageBounds(data)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		getLogger(c).Warn("failed to read image", slog.String("content_type", c.ContentType()), slog.Any("error", err))
+		respondWithError(c, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
 	}
-	if err := validateFormData(&form, image.Bounds()); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+	// validate form data
+	negotiateFormat(c, &form)
+	err = validateFormData(&form, bounds)
+	setMetricLabels(c, form)
+	if err != nil {
+		var validationError ValidationError
+		errors.As(err, &validationError)
+		fields := []string{}
+		for _, fieldError := range validationError {
+			fields = append(fields, fieldError.Field)
+		}
+		getLogger(c).Warn("validation failed", slog.Any("fields", fields), slog.Any("error", err))
+		respondWithError(c, http.StatusBadRequest, ErrorResponse{Error: err.Error(), Errors: validationError})
 		return
 	}
 
 	// determine encoding settings
-	encodingSettings, err := getEncodingSettings(*form.Style)
+	encodingSettings, err := getEncodingSettings(form)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		getLogger(c).Warn("invalid encoding settings", slog.Any("error", err))
+		respondWithError(c, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// an ascii whose settings cannot be keyed is neither cached nor tagged, so that it never shares a key with another ascii
+	store, etag := cache, ""
+	key, err := getCacheKey(data, form)
+	if err != nil {
+		getLogger(c).Warn("failed to compute cache key", slog.Any("error", err))
+		store = NoCache{}
+	} else {
+		etag = getETag(key, *form.Format)
+	}
+
+	// if the client already has this ascii, in this format, there is nothing to send
+	if etag != "" && isETagMatch(c.GetHeader("If-None-Match"), etag) {
+		c.Header("ETag", etag)
+		c.Status(http.StatusNotModified)
 		return
 	}
 
-	// attempt to generate ascii
-	ascii := generateAscii(image, form, encodingSettings)
-	c.IndentedJSON(http.StatusOK, ascii)
+	attrs := []slog.Attr{
+		slog.String("style", *form.Style),
+		slog.String("format", *form.Format),
+		slog.Int("width", *form.Width),
+		slog.Int("height", *form.Height),
+		slog.Int("source_width", bounds.Dx()),
+		slog.Int("source_height", bounds.Dy()),
+	}
+
+	// use the cached ascii if there is one, otherwise attempt to generate it
+	ascii, ok := store.Get(key)
+	cacheResult := CACHE_HIT
+	if !ok {
+		cacheResult = CACHE_MISS
+
+		// wait for enough capacity to convert an ascii of this size
+		if !acquireConversionWeight(c, getConversionWeight(form)) {
+			rejectBusy(c)
+			return
+		}
+
+		decodeStart := time.Now()
+		image, err := decodeImage(data)
+		if err != nil {
+			getLogger(c).Warn("failed to decode image", slog.Any("error", err))
+			respondWithError(c, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		decodeTime := time.Since(decodeStart)
+		observeImage(image.Bounds())
+
+		conversionStart := time.Now()
+		ascii = generateAsciiInstrumented(image, form, encodingSettings)
+		store.Set(key, ascii)
+		attrs = append(attrs,
+			slog.Float64("decode_ms", getMilliseconds(decodeTime)),
+			slog.Float64("conversion_ms", getMilliseconds(time.Since(conversionStart))),
+		)
+	}
+	observeCache(cacheResult)
+
+	// the ascii is only tagged once it exists, so that an error response never carries a tag a client could send back
+	if etag != "" {
+		c.Header("ETag", etag)
+	}
+	c.Header("X-Cache", cacheResult)
+	attrs = append(attrs, slog.String("cache", cacheResult))
+	getLogger(c).LogAttrs(c.Request.Context(), slog.LevelInfo, "conversion complete", attrs...)
+
+	switch *form.Format {
+	case FORMAT_TEXT:
+		c.Data(http.StatusOK, binding.MIMEPlain+"; charset=utf-8", []byte(strings.Join(ascii, "\n")+"\n"))
+	case FORMAT_DETAILED:
+		// gin only sets the JSON content type if none is set, so the vendor media type is kept
+		c.Header("Content-Type", MIME_DETAILED+"; charset=utf-8")
+		c.IndentedJSON(http.StatusOK, getDetailedResponse(ascii, form, bounds, start))
+	default:
+		c.IndentedJSON(http.StatusOK, ascii)
+	}
 }
 
 // getWebClient is the function executed when a user does a GET request to "/".
 // This simply returns a templated HTML file.
 func getWebClient(c *gin.Context) {
-	styleOptions := []Option{
-		{Value: STYLE_NORMAL, Label: "Normal"},
-		{Value: STYLE_HIGH_CONTRAST, Label: "High Contrast"},
-		{Value: STYLE_EDGE_CONTRAST, Label: "Edge Contrast"},
-		{Value: STYLE_SMOOTH, Label: "Smooth"},
-		{Value: STYLE_BRIGHTNESS, Label: "Brightness"},
-	}
-
 	data := gin.H{
-		"styleOptions": styleOptions,
+		"styleOptions":    getWebStyleOptions(),
+		"equalizeOptions": getEqualizeOptions(),
+		"capabilities":    buildCapabilities(),
+		"defaultStyle":    config.DefaultStyle,
 		"names": gin.H{
-			"image":    FORM_IMAGE_NAME,
-			"theme":    FORM_THEME_NAME,
-			"width":    FORM_WIDTH_NAME,
-			"height":   FORM_HEIGHT_NAME,
-			"invert":   FORM_INVERT_NAME,
-			"exposure": FORM_EXPOSURE_NAME,
-			"style":    FORM_STYLE_NAME,
+			"image":         FORM_IMAGE_NAME,
+			"theme":         FORM_THEME_NAME,
+			"width":         FORM_WIDTH_NAME,
+			"height":        FORM_HEIGHT_NAME,
+			"invert":        FORM_INVERT_NAME,
+			"exposure":      FORM_EXPOSURE_NAME,
+			"style":         FORM_STYLE_NAME,
+			"brightness":    FORM_BRIGHTNESS_NAME,
+			"contrast":      FORM_CONTRAST_NAME,
+			"gamma":         FORM_GAMMA_NAME,
+			"blackPoint":    FORM_BLACK_POINT_NAME,
+			"whitePoint":    FORM_WHITE_POINT_NAME,
+			"equalize":      FORM_EQUALIZE_NAME,
+			"sharpenAmount": FORM_SHARPEN_AMOUNT_NAME,
+			"diffusion":     FORM_DIFFUSION_NAME,
+			"clamp":         FORM_CLAMP_NAME,
 		},
 	}
 
 	c.HTML(http.StatusOK, "index.html", data)
 }
 
-// main establishes our server, and listens for GET and POST requests.
+// main loads the configuration, establishes our server, and listens for GET and POST requests.
 func main() {
-	router := gin.Default()
-	router.LoadHTMLGlob("templates/*")
-	router.Static("/static", "./static")
-	router.Static("/assets", "./assets")
+	slog.SetDefault(newLogger())
 
-	// Favicons
-	router.StaticFile("/favicon.ico", "./assets/favicon.ico")
-	router.StaticFile("/icon.svg", "./assets/icon.svg")
-	router.StaticFile("/apple-touch-icon.png", "./assets/apple-touch-icon.png")
-	router.StaticFile("/og-image.png", "./assets/og-image.png")
+	cfg, err := loadConfig(os.Args[1:])
+	if err != nil {
+		slog.Error("invalid configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+	config = cfg
+
+	// gin's debug mode is only needed in dev mode, where it reloads templates on each request
+	if !config.Dev {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	router := gin.New()
+	router.Use(logRequests(), recoverRequests())
+
+	// only trust the client IP set by a trusted proxy
+	router.RemoteIPHeaders = []string{"X-Real-IP"}
+	if err := router.SetTrustedProxies(config.TrustedProxies); err != nil {
+		slog.Error("invalid trusted proxies", slog.Any("error", err))
+		os.Exit(1)
+	}
+	loadTemplates(router)
+	serveFiles(router)
+
+	cache, err = newCache()
+	if err != nil {
+		slog.Error("invalid cache", slog.Any("error", err))
+		os.Exit(1)
+	}
 
 	// web client
 	router.GET("/", getWebClient)
 
-	// api
-	router.POST("/api", getAscii)
+	// health & build info
+	router.GET("/healthz", getHealthz)
+	router.GET("/readyz", getReadyz)
+	router.GET("/version", getVersion)
 
-	router.Run("localhost:8080")
+	// metrics
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// api
+	instrument := instrumentRequests()
+	rateLimit := limitRate()
+	limit := limitConversions(config.MaxConversions)
+	router.POST("/api", instrument, rateLimit, limit, getAscii)
+	v1 := router.Group(API_V1_PREFIX)
+	v1.POST("/convert", instrument, rateLimit, limit, getAscii)
+	v1.GET("/openapi.json", getOpenAPI)
+	v1.GET("/capabilities", getCapabilities)
+
+	if err := runServer(newServer(router)); err != nil {
+		slog.Error("server stopped", slog.Any("error", err))
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,577 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// image types read directly by getLuminanceSampler, along with a type it falls back to img.At for
+var testImageTypes = []string{"rgba", "nrgba", "ycbcr", "paletted", "gray"}
+
+// styles converted by the tests & benchmarks. Custom is excluded, since it requires a kernel.
+var testStyles = []string{
+	STYLE_NORMAL,
+	STYLE_BRIGHTNESS,
+	STYLE_HIGH_CONTRAST,
+	STYLE_EDGE_CONTRAST,
+	STYLE_SMOOTH,
+	STYLE_LINE_ART,
+	STYLE_BLUE_NOISE,
+	STYLE_HALFTONE,
+}
+
+// genericImage hides the concrete type of an image, so that getLuminanceSampler falls back to img.At.
+type genericImage struct {
+	image.Image
+}
+
+// newTestImages returns an image of each type in testImageTypes, with dimensions `width` x `height`. Each image holds a noisy
+// gradient, and the NRGBA image also holds partially transparent pixels.
+func newTestImages(width, height int) map[string]image.Image {
+	r := rand.New(rand.NewSource(1))
+	bounds := image.Rect(0, 0, width, height)
+	rgba := image.NewRGBA(bounds)
+	nrgba := image.NewNRGBA(bounds)
+	paletted := image.NewPaletted(bounds, palette.Plan9)
+	gray := image.NewGray(bounds)
+
+	for y := range height {
+		for x := range width {
+			v := uint8((x*255/width + y*255/height) / 2)
+			pixel := color.RGBA{R: v, G: v/2 + uint8(r.Intn(64)), B: 255 - v, A: 255}
+			alpha := uint8(255)
+			if (x/7+y/5)%9 == 0 {
+				alpha = uint8(x % 256)
+			}
+
+			rgba.SetRGBA(x, y, pixel)
+			nrgba.SetNRGBA(x, y, color.NRGBA{R: pixel.R, G: pixel.G, B: pixel.B, A: alpha})
+			paletted.Set(x, y, pixel)
+			gray.Set(x, y, pixel)
+		}
+	}
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, rgba, &jpeg.Options{Quality: 90}); err != nil {
+		panic(err)
+	}
+	ycbcr, err := jpeg.Decode(&encoded)
+	if err != nil {
+		panic(err)
+	}
+
+	return map[string]image.Image{"rgba": rgba, "nrgba": nrgba, "ycbcr": ycbcr, "paletted": paletted, "gray": gray}
+}
+
+// newTestForm returns a validated form converting img to an ascii of width `width` in `style`, along with it's encoding
+// settings. If set, `adjust` is applied to the form before it is validated.
+func newTestForm(tb testing.TB, img image.Image, width int, style string, adjust func(f *FormData)) (FormData, EncodingSettings) {
+	tb.Helper()
+
+	form := FormData{Width: &width, Style: &style}
+	if adjust != nil {
+		adjust(&form)
+	}
+	if err := validateFormData(&form, img.Bounds()); err != nil {
+		tb.Fatalf("invalid form: %v", err)
+	}
+
+	encodingSettings, err := getEncodingSettings(form)
+	if err != nil {
+		tb.Fatalf("invalid encoding settings: %v", err)
+	}
+	return form, encodingSettings
+}
+
+// testAdjustments are applied to the form of each conversion in TestGenerateAsciiMatchesReference, so that every step of the
+// pipeline is covered.
+var testAdjustments = map[string]func(f *FormData){
+	"defaults": nil,
+	"tuned": func(f *FormData) {
+		brightness, contrast, gamma, blackPoint, whitePoint := 10.0, 20.0, 1.4, 5.0, 90.0
+		equalize, tileSize, clipLimit := EQUALIZE_CLAHE, 16, 3.0
+		amount, radius, threshold := 80.0, 1.5, 2.0
+		f.Brightness, f.Contrast, f.Gamma, f.BlackPoint, f.WhitePoint = &brightness, &contrast, &gamma, &blackPoint, &whitePoint
+		f.Equalize, f.TileSize, f.ClipLimit = &equalize, &tileSize, &clipLimit
+		f.SharpenAmount, f.SharpenRadius, f.SharpenThreshold = &amount, &radius, &threshold
+	},
+	"inverted": func(f *FormData) {
+		equalize := EQUALIZE_GLOBAL
+		f.Equalize = &equalize
+		f.IsInvert = CHECKBOX_CHECKED
+	},
+}
+
+// getReferenceLuminance returns the luminance of pixel, computed with linearizeChannel rather than the lookup table.
+func getReferenceLuminance(pixel color.Color) float64 {
+	r, g, b, a := pixel.RGBA()
+	opacity := float64(uint8(a>>8) / 255.0)
+	red := getColor(uint8(r>>8), opacity)
+	green := getColor(uint8(g>>8), opacity)
+	blue := getColor(uint8(b>>8), opacity)
+
+	return getLuminance(linearizeChannel(red), linearizeChannel(green), linearizeChannel(blue))
+}
+
+// withSingleCPU runs fn with GOMAXPROCS set to 1, so that parallelRows processes each row in order.
+func withSingleCPU(fn func()) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	fn()
+}
+
+// withCPUs runs fn with GOMAXPROCS set to at least `n`, so that parallelRows splits rows across goroutines, even on a machine
+// with fewer CPUs.
+func withCPUs(n int, fn func()) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(max(n, runtime.GOMAXPROCS(0))))
+	fn()
+}
+
+// setConfig applies `adjust` to the configuration for the rest of the test, restoring the previous configuration once it
+// completes.
+func setConfig(t *testing.T, adjust func(c *Config)) {
+	t.Helper()
+
+	previous := config
+	t.Cleanup(func() { config = previous })
+	adjust(&config)
+}
+
+func TestGetGrayscaleMatrixMatchesReference(t *testing.T) {
+	for _, size := range []image.Point{{X: 640, Y: 480}, {X: 30, Y: 23}} {
+		images := newTestImages(size.X, size.Y)
+		totalWidth, totalHeight := 200, 150
+
+		for _, name := range testImageTypes {
+			img := images[name]
+			t.Run(fmt.Sprintf("%s/%dx%d", name, size.X, size.Y), func(t *testing.T) {
+				var grayscale *LuminanceBuffer
+				withCPUs(4, func() { grayscale = getGrayscaleMatrix(img, totalWidth, totalHeight) })
+				defer releaseLuminanceBuffer(grayscale)
+
+				scaleX, scaleY := float64(totalWidth)/float64(size.X), float64(totalHeight)/float64(size.Y)
+				for y := range totalHeight {
+					for x := range totalWidth {
+						originalX, originalY := int(math.Round(float64(x)/scaleX)), int(math.Round(float64(y)/scaleY))
+						expected := float32(getReferenceLuminance(img.At(originalX, originalY)))
+						if actual := grayscale.At(x, y); actual != expected {
+							t.Fatalf("luminance at (%d, %d): expected %v, got %v", x, y, expected, actual)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestGenerateAsciiMatchesReference converts each test image in each style, with each set of adjustments, at two sizes (240
+// conversions), and ensures the output is the same as converting the image through img.At, one row at a time.
+func TestGenerateAsciiMatchesReference(t *testing.T) {
+	sizes := []struct {
+		Width, Height, AsciiWidth int
+	}{
+		{Width: 640, Height: 480, AsciiWidth: 200},
+		{Width: 30, Height: 23, AsciiWidth: 100},
+	}
+
+	for _, size := range sizes {
+		images := newTestImages(size.Width, size.Height)
+		for _, name := range testImageTypes {
+			img := images[name]
+			for _, style := range testStyles {
+				for adjustment, adjust := range testAdjustments {
+					t.Run(fmt.Sprintf("%dx%d/%s/%s/%s", size.Width, size.Height, name, style, adjustment), func(t *testing.T) {
+						form, encodingSettings := newTestForm(t, img, size.AsciiWidth, style, adjust)
+
+						var expected, actual []string
+						withSingleCPU(func() { expected = generateAscii(genericImage{img}, form, encodingSettings) })
+						withCPUs(4, func() { actual = generateAscii(img, form, encodingSettings) })
+
+						expectedHash := sha256.Sum256([]byte(strings.Join(expected, "\n")))
+						actualHash := sha256.Sum256([]byte(strings.Join(actual, "\n")))
+						if actualHash != expectedHash {
+							t.Fatalf("ascii differs from reference: expected %x, got %x", expectedHash[:8], actualHash[:8])
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestGetFormTypeErrors(t *testing.T) {
+	errs := getFormTypeErrors(map[string][]string{
+		FORM_WIDTH_NAME:    {"abc"},
+		FORM_EXPOSURE_NAME: {"x"},
+		FORM_STYLE_NAME:    {STYLE_NORMAL},
+		FORM_HEIGHT_NAME:   {"20"},
+	})
+
+	fields := []string{}
+	for _, fieldError := range errs {
+		if fieldError.Code != ERROR_INVALID_TYPE {
+			t.Errorf("%s: expected code %s, got %s", fieldError.Field, ERROR_INVALID_TYPE, fieldError.Code)
+		}
+		fields = append(fields, fieldError.Field)
+	}
+	if expected := []string{FORM_WIDTH_NAME, FORM_EXPOSURE_NAME}; !slices.Equal(fields, expected) {
+		t.Errorf("expected fields %v, got %v", expected, fields)
+	}
+}
+
+func TestGetJSONTypeErrors(t *testing.T) {
+	body := []byte(`{"image": 5, "width": "abc", "invert": true, "kernel": [1], "exposure": 20}`)
+
+	fields := []string{}
+	for _, fieldError := range getJSONTypeErrors(body) {
+		fields = append(fields, fieldError.Field)
+	}
+	if expected := []string{FORM_WIDTH_NAME, FORM_KERNEL_NAME, FORM_IMAGE_NAME}; !slices.Equal(fields, expected) {
+		t.Errorf("expected fields %v, got %v", expected, fields)
+	}
+
+	if errs := getJSONTypeErrors([]byte(`{bad`)); len(errs) != 0 {
+		t.Errorf("expected no field errors for a malformed body, got %v", errs)
+	}
+}
+
+// nonFiniteCases set a single float attribute of a form to a non-finite value, along with any setting the attribute depends on.
+var nonFiniteCases = []struct {
+	Field string
+	Set   func(f *FormData, value *float64)
+}{
+	{Field: FORM_EXPOSURE_NAME, Set: func(f *FormData, value *float64) { f.Exposure = value }},
+	{Field: FORM_GAMMA_NAME, Set: func(f *FormData, value *float64) { f.Gamma = value }},
+	{Field: FORM_BLUR_NAME, Set: func(f *FormData, value *float64) { f.Blur = value }},
+	{Field: FORM_SHARPEN_RADIUS_NAME, Set: func(f *FormData, value *float64) { f.SharpenRadius = value }},
+	{Field: FORM_CLIP_LIMIT_NAME, Set: func(f *FormData, value *float64) {
+		equalize := EQUALIZE_CLAHE
+		f.Equalize, f.ClipLimit = &equalize, value
+	}},
+	{Field: FORM_ANGLE_NAME, Set: func(f *FormData, value *float64) {
+		style := STYLE_HALFTONE
+		f.Style, f.Angle = &style, value
+	}},
+	{Field: FORM_DIFFUSION_NAME, Set: func(f *FormData, value *float64) { f.Diffusion = value }},
+	{Field: FORM_CLAMP_MIN_NAME, Set: func(f *FormData, value *float64) { f.ClampMin = value }},
+	{Field: FORM_CLAMP_MAX_NAME, Set: func(f *FormData, value *float64) { f.ClampMax = value }},
+}
+
+// TestValidateFormDataRejectsNonFinite ensures that NaN & infinite values, which fail every range comparison, are rejected as out
+// of range, and that the resulting error can still be encoded as JSON.
+func TestValidateFormDataRejectsNonFinite(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 100)
+	for _, c := range nonFiniteCases {
+		for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+			t.Run(fmt.Sprintf("%s/%v", c.Field, value), func(t *testing.T) {
+				width, style := 50, STYLE_NORMAL
+				form := FormData{Width: &width, Style: &style}
+				c.Set(&form, &value)
+
+				var validationError ValidationError
+				if err := validateFormData(&form, bounds); !errors.As(err, &validationError) {
+					t.Fatalf("expected a validation error, got %v", err)
+				}
+				if len(validationError) != 1 || validationError[0].Field != c.Field || validationError[0].Code != ERROR_OUT_OF_RANGE {
+					t.Fatalf("expected a single %s error for %s, got %+v", ERROR_OUT_OF_RANGE, c.Field, validationError)
+				}
+				if _, err := json.Marshal(ErrorResponse{Errors: validationError}); err != nil {
+					t.Fatalf("failed to encode error: %v", err)
+				}
+			})
+		}
+	}
+}
+
+// floatPtr returns a pointer to `value`, used to set the optional float attributes of a form.
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+func TestValidateClamp(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Min, Max *float64
+		Code     string
+	}{
+		{Name: "defaults"},
+		{Name: "valid", Min: floatPtr(20.0), Max: floatPtr(80.0)},
+		{Name: "equal", Min: floatPtr(50.0), Max: floatPtr(50.0)},
+		{Name: "conflict", Min: floatPtr(80.0), Max: floatPtr(20.0), Code: ERROR_CONFLICT},
+		{Name: "below range", Min: floatPtr(MIN_CLAMP - 1), Code: ERROR_OUT_OF_RANGE},
+		{Name: "above range", Max: floatPtr(MAX_CLAMP + 1), Code: ERROR_OUT_OF_RANGE},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			form := FormData{ClampMin: c.Min, ClampMax: c.Max}
+			err := validateClamp(&form)
+
+			if c.Code == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if c.Min == nil && *form.ClampMin != DEFAULT_CLAMP_MIN || c.Max == nil && *form.ClampMax != DEFAULT_CLAMP_MAX {
+					t.Errorf("expected defaults %v & %v, got %v & %v", DEFAULT_CLAMP_MIN, DEFAULT_CLAMP_MAX, *form.ClampMin, *form.ClampMax)
+				}
+				return
+			}
+
+			if errs := (ValidationError{}).Add(err); len(errs) != 1 || errs[0].Code != c.Code {
+				t.Fatalf("expected a %s error, got %v", c.Code, err)
+			}
+		})
+	}
+}
+
+// setCache replaces the cache of generated ASCIIs with `c` for the rest of the test.
+func setCache(t *testing.T, c Cache) {
+	t.Helper()
+
+	previous := cache
+	t.Cleanup(func() { cache = previous })
+	cache = c
+}
+
+// newTestPNG returns a small image, encoded as a PNG.
+func newTestPNG(tb testing.TB) []byte {
+	tb.Helper()
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, newTestImages(40, 30)["rgba"]); err != nil {
+		tb.Fatalf("failed to encode image: %v", err)
+	}
+	return encoded.Bytes()
+}
+
+// serveAscii sends the raw image `data` to getAscii, with `query` as it's settings, and `headers` set on the request. Any
+// `handlers` are run before getAscii.
+func serveAscii(data []byte, query string, headers map[string]string, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api", append(handlers, getAscii)...)
+
+	request := httptest.NewRequest(http.MethodPost, "/api?"+query, bytes.NewReader(data))
+	request.Header.Set("Content-Type", MIME_PNG)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	row := strings.Repeat("#", 100)
+	entrySize := getEntrySize("a", []string{row})
+	m := newMemoryCache(entrySize * 2)
+
+	m.Set("a", []string{row})
+	m.Set("b", []string{row})
+	m.Get("a")
+	m.Set("c", []string{row})
+
+	for key, expected := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := m.Get(key); ok != expected {
+			t.Errorf("%s: expected cached to be %v", key, expected)
+		}
+	}
+	if m.bytes != entrySize*2 {
+		t.Errorf("expected %d bytes, got %d", entrySize*2, m.bytes)
+	}
+
+	m.Set("large", []string{row, row, row, row, row})
+	if _, ok := m.Get("large"); ok {
+		t.Error("expected an entry larger than the cache not to be stored")
+	}
+	if _, ok := m.Get("c"); !ok {
+		t.Error("expected an entry larger than the cache not to evict other entries")
+	}
+}
+
+func TestTieredCachePromotion(t *testing.T) {
+	disk, err := newDiskCache(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	memory := newMemoryCache(1 << 20)
+	tiered := TieredCache{Memory: memory, Disk: disk}
+
+	ascii := []string{"@@##", ".:-="}
+	disk.Set("key", ascii)
+	if _, ok := memory.Get("key"); ok {
+		t.Fatal("expected memory to start empty")
+	}
+
+	if cached, ok := tiered.Get("key"); !ok || !slices.Equal(cached, ascii) {
+		t.Fatalf("expected %q from disk, got %q", ascii, cached)
+	}
+	if cached, ok := memory.Get("key"); !ok || !slices.Equal(cached, ascii) {
+		t.Errorf("expected %q to be promoted to memory, got %q", ascii, cached)
+	}
+}
+
+func TestGetAsciiCaching(t *testing.T) {
+	setCache(t, newMemoryCache(1<<20))
+	data := newTestPNG(t)
+
+	first := serveAscii(data, "width=20", nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Header().Get("X-Cache") != CACHE_MISS {
+		t.Fatalf("expected a 200 miss with an ETag, got %d %q %q", first.Code, first.Header().Get("X-Cache"), etag)
+	}
+
+	second := serveAscii(data, "width=20", nil)
+	if second.Code != http.StatusOK || second.Header().Get("X-Cache") != CACHE_HIT || second.Body.String() != first.Body.String() {
+		t.Errorf("expected an identical 200 hit, got %d %q", second.Code, second.Header().Get("X-Cache"))
+	}
+
+	notModified := serveAscii(data, "width=20", map[string]string{"If-None-Match": etag})
+	if notModified.Code != http.StatusNotModified || notModified.Header().Get("ETag") != etag || notModified.Body.Len() != 0 {
+		t.Errorf("expected an empty 304 tagged %s, got %d %q with %d bytes", etag, notModified.Code, notModified.Header().Get("ETag"), notModified.Body.Len())
+	}
+
+	other := serveAscii(data, "width=21", map[string]string{"If-None-Match": etag})
+	if other.Code != http.StatusOK || other.Header().Get("ETag") == etag {
+		t.Errorf("expected a 200 with a new ETag for different settings, got %d %q", other.Code, other.Header().Get("ETag"))
+	}
+}
+
+// TestGetAsciiErrorsHaveNoETag ensures that only an ASCII is tagged, so that a client can never send back the tag of an error,
+// and be told it's error is still current.
+func TestGetAsciiErrorsHaveNoETag(t *testing.T) {
+	setCache(t, NoCache{})
+	data := newTestPNG(t)
+
+	// a slot on a full semaphore, so that every conversion is rejected as busy
+	busy := func(c *gin.Context) {
+		semaphore := newWeightedSemaphore(1)
+		semaphore.TryAcquire(1)
+		c.Set(CONTEXT_CONVERSION_SLOT_KEY, &ConversionSlot{semaphore: semaphore})
+	}
+
+	cases := []struct {
+		Name     string
+		Data     []byte
+		Query    string
+		Handlers []gin.HandlerFunc
+		Expected int
+	}{
+		{Name: "invalid settings", Data: data, Query: "width=0", Expected: http.StatusBadRequest},
+		{Name: "undecodable image", Data: data[:len(data)/2], Query: "width=20", Expected: http.StatusBadRequest},
+		{Name: "busy", Data: data, Query: "width=20", Handlers: []gin.HandlerFunc{busy}, Expected: http.StatusTooManyRequests},
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			response := serveAscii(c.Data, c.Query, nil, c.Handlers...)
+			if response.Code != c.Expected {
+				t.Fatalf("expected status %d, got %d: %s", c.Expected, response.Code, response.Body.String())
+			}
+			if etag := response.Header().Get("ETag"); etag != "" {
+				t.Errorf("expected no ETag, got %q", etag)
+			}
+		})
+	}
+}
+
+// TestGetAsciiRawContentTypes ensures that every advertised content type of a raw image body is accepted.
+func TestGetAsciiRawContentTypes(t *testing.T) {
+	setCache(t, NoCache{})
+	data := newTestPNG(t)
+
+	for _, contentType := range getContentTypes() {
+		if contentType == binding.MIMEMultipartPOSTForm || contentType == binding.MIMEJSON {
+			continue
+		}
+		t.Run(contentType, func(t *testing.T) {
+			response := serveAscii(data, "width=20", map[string]string{"Content-Type": contentType})
+			if response.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, response.Code, response.Body.String())
+			}
+		})
+	}
+
+	response := serveAscii(data, "width=20", map[string]string{"Content-Type": "image/gif"})
+	if response.Code != http.StatusUnsupportedMediaType || !strings.Contains(response.Body.String(), MIME_JPG) {
+		t.Errorf("expected a 415 listing %s, got %d: %s", MIME_JPG, response.Code, response.Body.String())
+	}
+}
+
+func TestDecodeBase64Image(t *testing.T) {
+	// encodes to both characters that differ between the standard & URL-safe alphabets, and to padding
+	data := []byte{0xfb, 0xff, 0xbf, 0xfe, 0x00}
+
+	cases := map[string]string{
+		"standard":     base64.StdEncoding.EncodeToString(data),
+		"raw standard": base64.RawStdEncoding.EncodeToString(data),
+		"url":          base64.URLEncoding.EncodeToString(data),
+		"raw url":      base64.RawURLEncoding.EncodeToString(data),
+		"data url":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(data),
+		"raw data url": "data:image/png;base64," + base64.RawURLEncoding.EncodeToString(data),
+	}
+	for name, encoded := range cases {
+		t.Run(name, func(t *testing.T) {
+			decoded, err := decodeBase64Image(encoded)
+			if err != nil {
+				t.Fatalf("failed to decode %q: %v", encoded, err)
+			}
+			if !bytes.Equal(decoded, data) {
+				t.Errorf("expected %x, got %x", data, decoded)
+			}
+		})
+	}
+
+	for _, encoded := range []string{"", "not base64!", "+_8"} {
+		if decoded, err := decodeBase64Image(encoded); err == nil {
+			t.Errorf("expected an error decoding %q, got %x", encoded, decoded)
+		}
+	}
+}
+
+func BenchmarkGetGrayscaleMatrix(b *testing.B) {
+	images := newTestImages(2000, 2000)
+	for _, name := range testImageTypes {
+		img := images[name]
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				releaseLuminanceBuffer(getGrayscaleMatrix(img, 1000, 1000))
+			}
+		})
+	}
+}
+
+func BenchmarkGenerateAscii(b *testing.B) {
+	images := newTestImages(2000, 2000)
+	for _, name := range testImageTypes {
+		img := images[name]
+		for _, style := range testStyles {
+			form, encodingSettings := newTestForm(b, img, 500, style, nil)
+			b.Run(name+"/"+style, func(b *testing.B) {
+				b.ReportAllocs()
+				for range b.N {
+					generateAscii(img, form, encodingSettings)
+				}
+			})
+		}
+	}
+}
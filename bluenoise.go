@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"image"
+	"image/png"
+)
+
+// side length of the blue noise texture, in pixels
+const BLUE_NOISE_SIZE = 64
+
+// blueNoisePNG is a precomputed, tileable blue noise texture, generated with the void-and-cluster method.
+// Each pixel stores it's unique rank (0 to `BLUE_NOISE_SIZE`²-1), scaled to fill a 16-bit grayscale value.
+// For more information, see: https://en.wikipedia.org/wiki/Ordered_dithering#Blue_noise
+//
+//go:embed textures/bluenoise.png
+var blueNoisePNG []byte
+
+// blueNoise holds the decoded blue noise texture as thresholds between 0.0 and 1.0, indexed as blueNoise[y][x].
+var blueNoise = loadBlueNoise(blueNoisePNG)
+
+// loadBlueNoise decodes the embedded blue noise texture into a matrix of thresholds between 0.0 and 1.0, where each rank maps
+// to the center of it's slice of the threshold range.
+// Since the texture is embedded at build time, a texture that fails to decode is a programming error, and will panic.
+func loadBlueNoise(data []byte) [][]float64 {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		panic("bluenoise: failed to decode texture: " + err.Error())
+	}
+	gray, ok := img.(*image.Gray16)
+	if !ok || gray.Bounds().Dx() != BLUE_NOISE_SIZE || gray.Bounds().Dy() != BLUE_NOISE_SIZE {
+		panic("bluenoise: texture must be a 16-bit grayscale square image")
+	}
+
+	ranks := BLUE_NOISE_SIZE * BLUE_NOISE_SIZE
+	step := 65536 / ranks
+	thresholds := make([][]float64, BLUE_NOISE_SIZE)
+	for y := range thresholds {
+		thresholds[y] = make([]float64, BLUE_NOISE_SIZE)
+		for x := range thresholds[y] {
+			rank := int(gray.Gray16At(x, y).Y) / step
+			thresholds[y][x] = (float64(rank) + 0.5) / float64(ranks)
+		}
+	}
+
+	return thresholds
+}
+
+// getBlueNoiseThreshold returns the blue noise threshold at `point`, a value between 0.0 and 1.0, by tiling the blue noise
+// texture over the entire image.
+func getBlueNoiseThreshold(point Point) float64 {
+	return blueNoise[point.Y%BLUE_NOISE_SIZE][point.X%BLUE_NOISE_SIZE]
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// version of the cache key. Must be incremented whenever a change to the conversion pipeline changes it's output, or the on-disk
+// cache changes how it stores an ASCII, so that stale entries in an on-disk cache are never served.
+const CACHE_KEY_VERSION = 3
+
+// cache defaults
+const (
+	DEFAULT_CACHE_SIZE     = 64 << 20
+	DEFAULT_CACHE_DIR      = ""
+	DEFAULT_CACHE_DIR_SIZE = 1 << 30
+)
+
+// extension of the files written by the on-disk cache. Only files with this extension are counted or removed, so that the cache
+// never deletes files it did not write.
+const CACHE_FILE_EXT = ".ascii"
+
+// estimated memory used by a cached entry, beyond it's key & rows
+const CACHE_ENTRY_OVERHEAD = 128
+
+// when the on-disk cache grows beyond it's size, the least recently used files are removed until it is back under this fraction
+// of it's size, so that the directory is not swept on every write
+const CACHE_DIR_SWEEP_TARGET = 0.9
+
+// cache results, used to label metrics & logs
+const (
+	CACHE_HIT  = "hit"
+	CACHE_MISS = "miss"
+)
+
+// cache is the cache of generated ASCIIs. It is set once by main, before the server starts.
+var cache Cache = NoCache{}
+
+// Cache interface, describing a store of generated ASCIIs, keyed by getCacheKey
+type Cache interface {
+	Get(key string) ([]string, bool)
+	Set(key string, ascii []string)
+}
+
+// No-op cache struct, used when caching is disabled
+type NoCache struct{}
+
+func (NoCache) Get(key string) ([]string, bool) { return nil, false }
+func (NoCache) Set(key string, ascii []string)  {}
+
+// Memory cache entry struct
+type MemoryCacheEntry struct {
+	Key   string
+	Ascii []string
+	Size  int
+}
+
+// Memory cache struct, that holds the most recently used ASCIIs in memory, up to a total size in bytes
+type MemoryCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+// newMemoryCache returns an empty memory cache, holding up to `maxBytes` bytes.
+func newMemoryCache(maxBytes int) *MemoryCache {
+	return &MemoryCache{maxBytes: maxBytes, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// getEntrySize estimates the memory used by an entry.
+func getEntrySize(key string, ascii []string) int {
+	size := len(key) + CACHE_ENTRY_OVERHEAD
+	for _, row := range ascii {
+		size += len(row)
+	}
+	return size
+}
+
+// Get returns the ASCII stored under `key`, marking it as the most recently used.
+func (m *MemoryCache) Get(key string) ([]string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	m.order.MoveToFront(element)
+	return element.Value.(*MemoryCacheEntry).Ascii, true
+}
+
+// Set stores ascii under `key`, evicting the least recently used entries until the cache fits within it's size. ASCIIs larger
+// than the whole cache are not stored.
+func (m *MemoryCache) Set(key string, ascii []string) {
+	size := getEntrySize(key, ascii)
+	if size > m.maxBytes {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if element, ok := m.entries[key]; ok {
+		m.order.MoveToFront(element)
+		return
+	}
+
+	m.entries[key] = m.order.PushFront(&MemoryCacheEntry{Key: key, Ascii: ascii, Size: size})
+	m.bytes += size
+	for m.bytes > m.maxBytes {
+		oldest := m.order.Back()
+		entry := oldest.Value.(*MemoryCacheEntry)
+		m.order.Remove(oldest)
+		delete(m.entries, entry.Key)
+		m.bytes -= entry.Size
+	}
+}
+
+// Disk cache struct, that stores each ASCII as a JSON file in a directory, up to a total size in bytes. The least recently used
+// files are removed first, based on their modification time, which is updated on each read.
+type DiskCache struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	bytes    int64
+}
+
+// newDiskCache returns a disk cache storing files in `dir`, holding up to `maxBytes` bytes. The directory is created if it does
+// not exist, and any files already in it are kept.
+// Returns an error if the directory cannot be created or read.
+func newDiskCache(dir string, maxBytes int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &DiskCache{dir: dir, maxBytes: maxBytes}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && isCacheFile(info) {
+			d.bytes += info.Size()
+		}
+	}
+	return d, nil
+}
+
+// isCacheFile determines whether a file was written by the disk cache.
+func isCacheFile(info fs.FileInfo) bool {
+	return info.Mode().IsRegular() && filepath.Ext(info.Name()) == CACHE_FILE_EXT
+}
+
+// getPath returns the path of the file storing the ASCII under `key`.
+func (d *DiskCache) getPath(key string) string {
+	return filepath.Join(d.dir, key+CACHE_FILE_EXT)
+}
+
+// Get returns the ASCII stored under `key`, marking it as the most recently used. A file that cannot be decoded is removed, and
+// treated as a miss.
+func (d *DiskCache) Get(key string) ([]string, bool) {
+	path := d.getPath(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	ascii := []string{}
+	if err := json.Unmarshal(data, &ascii); err != nil {
+		slog.Warn("failed to read from cache", slog.String("path", path), slog.Any("error", err))
+		os.Remove(path)
+		return nil, false
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return ascii, true
+}
+
+// Set stores ascii under `key`, encoded as a JSON array of rows, so that every ASCII (including one with no rows) is read back
+// exactly as it was stored. The file is written to a temporary file first, and renamed into place, so that a partially written
+// file is never read.
+func (d *DiskCache) Set(key string, ascii []string) {
+	data, err := json.Marshal(ascii)
+	if err != nil || int64(len(data)) > d.maxBytes {
+		return
+	}
+	if _, err := os.Stat(d.getPath(key)); err == nil {
+		return
+	}
+
+	tmp, err := os.CreateTemp(d.dir, "tmp-*")
+	if err != nil {
+		slog.Warn("failed to write to cache", slog.Any("error", err))
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), d.getPath(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		slog.Warn("failed to write to cache", slog.Any("error", err))
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.bytes += int64(len(data))
+	if d.bytes > d.maxBytes {
+		d.sweep()
+	}
+}
+
+// sweep removes the least recently used files, until the cache is back under it's sweep target. Must be called with the lock
+// held.
+func (d *DiskCache) sweep() {
+	files := []fs.FileInfo{}
+	d.bytes = 0
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		slog.Warn("failed to sweep cache", slog.Any("error", err))
+		return
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && isCacheFile(info) {
+			files = append(files, info)
+			d.bytes += info.Size()
+		}
+	}
+
+	slices.SortFunc(files, func(a, b fs.FileInfo) int { return a.ModTime().Compare(b.ModTime()) })
+	target := int64(float64(d.maxBytes) * CACHE_DIR_SWEEP_TARGET)
+	for _, file := range files {
+		if d.bytes <= target {
+			break
+		}
+		if err := os.Remove(filepath.Join(d.dir, file.Name())); err == nil || errors.Is(err, fs.ErrNotExist) {
+			d.bytes -= file.Size()
+		}
+	}
+}
+
+// Tiered cache struct, that checks a memory cache before a disk cache. ASCIIs found on disk are promoted to memory.
+type TieredCache struct {
+	Memory Cache
+	Disk   Cache
+}
+
+func (t TieredCache) Get(key string) ([]string, bool) {
+	if ascii, ok := t.Memory.Get(key); ok {
+		return ascii, true
+	}
+	if ascii, ok := t.Disk.Get(key); ok {
+		t.Memory.Set(key, ascii)
+		return ascii, true
+	}
+	return nil, false
+}
+
+func (t TieredCache) Set(key string, ascii []string) {
+	t.Memory.Set(key, ascii)
+	t.Disk.Set(key, ascii)
+}
+
+// newCache returns the cache described by the configuration: a memory cache, backed by a disk cache when a cache directory is
+// set, or a no-op cache when the memory cache size is 0.
+// Returns an error if the cache directory cannot be used.
+func newCache() (Cache, error) {
+	if config.CacheSize <= 0 {
+		return NoCache{}, nil
+	}
+
+	memory := newMemoryCache(config.CacheSize)
+	if config.CacheDir == "" {
+		return memory, nil
+	}
+
+	disk, err := newDiskCache(config.CacheDir, int64(config.CacheDirSize))
+	if err != nil {
+		return nil, err
+	}
+	return TieredCache{Memory: memory, Disk: disk}, nil
+}
+
+// getCacheKey returns the key of an ASCII, a hash of the image bytes, and the normalized settings used to convert it.
+// Form is expected to be validated, so that requests which differ only in omitted defaults share a key. The format is excluded,
+// since it only changes how the ASCII is written, not the ASCII itself.
+// Returns an error if the settings cannot be encoded, in which case the ASCII should not be cached.
+func getCacheKey(data []byte, form FormData) (string, error) {
+	form.Format = nil
+	settings, err := json.Marshal(form)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte{CACHE_KEY_VERSION})
+	imageHash := sha256.Sum256(data)
+	hash.Write(imageHash[:])
+	hash.Write(settings)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// getETag returns the entity tag of a response, derived from the cache key of the ASCII, and the format it is written in. The
+// tag is weak, since the detailed format includes the processing time, which varies between responses.
+func getETag(key string, format string) string {
+	hash := sha256.Sum256([]byte(key + "/" + format))
+	return `W/"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// isETagMatch determines whether an If-None-Match header matches `etag`, using weak comparison.
+func isETagMatch(header string, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestGetCacheKey(t *testing.T) {
+	data := []byte("image")
+	width, otherWidth := 100, 120
+	style, otherStyle := STYLE_NORMAL, STYLE_SMOOTH
+	gamma := 1.4
+	textFormat, jsonFormat := FORMAT_TEXT, FORMAT_JSON
+
+	forms := map[string]FormData{
+		"base":  {Width: &width, Style: &style},
+		"width": {Width: &otherWidth, Style: &style},
+		"style": {Width: &width, Style: &otherStyle},
+		"gamma": {Width: &width, Style: &style, Gamma: &gamma},
+	}
+	keys := map[string]string{}
+	for name, form := range forms {
+		key, err := getCacheKey(data, form)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if other, ok := keys[key]; ok {
+			t.Errorf("%s & %s share the key %s", name, other, key)
+		}
+		keys[key] = name
+	}
+
+	textKey, _ := getCacheKey(data, FormData{Width: &width, Style: &style, Format: &textFormat})
+	jsonKey, _ := getCacheKey(data, FormData{Width: &width, Style: &style, Format: &jsonFormat})
+	if textKey != jsonKey {
+		t.Errorf("expected forms differing only in format to share a key, got %s & %s", textKey, jsonKey)
+	}
+
+	nan := math.NaN()
+	if key, err := getCacheKey(data, FormData{Width: &width, Style: &style, Gamma: &nan}); err == nil {
+		t.Errorf("expected an error for a NaN setting, got key %s", key)
+	}
+}
+
+func TestDiskCacheRoundTrip(t *testing.T) {
+	d, err := newDiskCache(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+
+	cases := map[string][]string{
+		"empty":      {},
+		"blank row":  {""},
+		"blank rows": {"", ""},
+		"rows":       {"@@##", "    ", ".:-="},
+		"trailing":   {"@@##", ""},
+	}
+	for name, ascii := range cases {
+		t.Run(name, func(t *testing.T) {
+			d.Set(name, ascii)
+			cached, ok := d.Get(name)
+			if !ok {
+				t.Fatal("expected a hit")
+			}
+			if cached == nil || !slices.Equal(cached, ascii) {
+				t.Errorf("expected %q, got %q", ascii, cached)
+			}
+		})
+	}
+
+	t.Run("corrupt", func(t *testing.T) {
+		if err := os.WriteFile(d.getPath("corrupt"), []byte("@@##\n.:-="), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		if cached, ok := d.Get("corrupt"); ok {
+			t.Errorf("expected a miss, got %q", cached)
+		}
+		if _, err := os.Stat(d.getPath("corrupt")); err == nil {
+			t.Error("expected the corrupt file to be removed")
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// character sets
+const (
+	CHARSET_BRAILLE = "braille"
+)
+
+// Limit struct to describe the range of values accepted by a form field
+type Limit struct {
+	Min any `json:"min"`
+	Max any `json:"max"`
+}
+
+// Capabilities struct to describe everything a client may need to know to build a request
+type Capabilities struct {
+	Styles         []Option         `json:"styles"`
+	EdgeMethods    []Option         `json:"edge_methods"`
+	Equalizations  []Option         `json:"equalizations"`
+	InputFormats   []string         `json:"input_formats"`
+	ContentTypes   []string         `json:"content_types"`
+	OutputFormats  []string         `json:"output_formats"`
+	Charsets       []string         `json:"charsets"`
+	Limits         map[string]Limit `json:"limits"`
+	Defaults       map[string]any   `json:"defaults"`
+	MaxKernelNodes int              `json:"max_kernel_nodes"`
+}
+
+// getStyleOptions returns every encoding style, along with it's label and a short description, in the order they are presented
+// to the user.
+func getStyleOptions() []Option {
+	return []Option{
+		{Value: STYLE_NORMAL, Label: "Normal", Description: "Floyd-Steinberg dithering. An all around algorithm that produces solid results in most cases."},
+		{Value: STYLE_HIGH_CONTRAST, Label: "High Contrast", Description: "Atkinson dithering. Produces softer results, and gives the exposure slider a greater effect."},
+		{Value: STYLE_EDGE_CONTRAST, Label: "Edge Contrast", Description: "Sierra Lite dithering. Similar to Normal, but with sharper edges."},
+		{Value: STYLE_SMOOTH, Label: "Smooth", Description: "Minimized average error dithering. Produces the smoothest results."},
+		{Value: STYLE_BRIGHTNESS, Label: "Brightness", Description: "No dithering. A pixel is on if its perceived brightness exceeds the exposure threshold."},
+		{Value: STYLE_LINE_ART, Label: "Line Art", Description: "Draws the outlines of the image, found using edge detection, instead of its tone."},
+		{Value: STYLE_BLUE_NOISE, Label: "Blue Noise", Description: "Ordered dithering with a blue noise threshold map, producing an even, grain-like texture."},
+		{Value: STYLE_HALFTONE, Label: "Halftone", Description: "Mimics newspaper print, with round dots on a rotated grid. Works best at larger sizes."},
+		{Value: STYLE_CUSTOM, Label: "Custom", Description: "Error diffusion using the kernel supplied in the kernel field."},
+	}
+}
+
+// getEdgeMethodOptions returns every edge detection method, along with it's label and a short description.
+func getEdgeMethodOptions() []Option {
+	return []Option{
+		{Value: EDGE_SOBEL, Label: "Sobel", Description: "Marks every pixel with a strong gradient. Produces thicker lines."},
+		{Value: EDGE_CANNY, Label: "Canny", Description: "Thins Sobel edges to single pixel lines, and connects them using both thresholds."},
+	}
+}
+
+// getEqualizeOptions returns every histogram equalization method, along with it's label and a short description.
+func getEqualizeOptions() []Option {
+	return []Option{
+		{Value: EQUALIZE_NONE, Label: "None", Description: "Leaves the tone of the image unchanged."},
+		{Value: EQUALIZE_GLOBAL, Label: "Global", Description: "Spreads tone evenly across the full range, using the histogram of the whole image."},
+		{Value: EQUALIZE_CLAHE, Label: "Adaptive (CLAHE)", Description: "Equalizes each tile of the image separately, limiting contrast amplification."},
+	}
+}
+
+// getOptionValues returns the value of each option.
+func getOptionValues(options []Option) []string {
+	values := make([]string, len(options))
+	for i, option := range options {
+		values[i] = option.Value
+	}
+	return values
+}
+
+// buildCapabilities returns the capabilities of the API. Limits & defaults are derived from the same field constraints used by
+// the OpenAPI document, keyed by form field name.
+func buildCapabilities() Capabilities {
+	limits, defaults := map[string]Limit{}, map[string]any{}
+	for name, constraints := range getFieldConstraints() {
+		if minVal, ok := constraints["minimum"]; ok {
+			limits[name] = Limit{Min: minVal, Max: constraints["maximum"]}
+		}
+		if defaultVal, ok := constraints["default"]; ok {
+			defaults[name] = defaultVal
+		}
+	}
+
+	return Capabilities{
+		Styles:         getStyleOptions(),
+		EdgeMethods:    getEdgeMethodOptions(),
+		Equalizations:  getEqualizeOptions(),
+		InputFormats:   []string{MIME_PNG, MIME_JPEG},
+		ContentTypes:   getContentTypes(),
+		OutputFormats:  getFormats(),
+		Charsets:       []string{CHARSET_BRAILLE},
+		Limits:         limits,
+		Defaults:       defaults,
+		MaxKernelNodes: MAX_KERNEL_NODES,
+	}
+}
+
+// getWebStyleOptions returns the styles presented by the web client, which excludes the custom style, since the web client
+// has no way to define a kernel.
+func getWebStyleOptions() []Option {
+	return slices.DeleteFunc(getStyleOptions(), func(option Option) bool {
+		return option.Value == STYLE_CUSTOM
+	})
+}
+
+// getCapabilities is the function executed when a user does a GET request to "/api/v1/capabilities".
+// This simply returns the capabilities of the API.
+func getCapabilities(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, buildCapabilities())
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"math"
+	"net"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// config defaults
+const (
+	DEFAULT_ADDRESS          = "localhost:8080"
+	DEFAULT_DEV              = false
+	DEFAULT_READ_TIMEOUT     = 30 * time.Second
+	DEFAULT_WRITE_TIMEOUT    = 60 * time.Second
+	DEFAULT_IDLE_TIMEOUT     = 120 * time.Second
+	DEFAULT_SHUTDOWN_TIMEOUT = 30 * time.Second
+	DEFAULT_RATE_LIMIT       = 2.0
+	DEFAULT_RATE_BURST       = 5
+	DEFAULT_MAX_BODY_SIZE    = 10 << 20
+)
+
+// largest allowed max length. The largest ASCII holds it's square in characters, and the conversion semaphore holds it's square
+// once per allowed conversion, so it is capped well before either grows unreasonably large.
+const MAX_LENGTH_LIMIT = 2000
+
+// proxies trusted by default to set the X-Real-IP header: nginx, running on the same host
+var DEFAULT_TRUSTED_PROXIES = []string{"127.0.0.1", "::1"}
+
+// prefix of every environment variable read by the config layer
+const ENV_PREFIX = "IMAGE2ASCII_"
+
+// Config struct to describe the runtime settings of the server
+type Config struct {
+	Address      string
+	MaxLength    int
+	MaxBodySize  int
+	DefaultStyle string
+	DefaultWidth int
+	TemplatesDir string
+	StaticDir    string
+	AssetsDir    string
+	Dev          bool
+
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	MaxConversions  int
+
+	RateLimit      float64
+	RateBurst      int
+	TrustedProxies []string
+
+	CacheSize    int
+	CacheDir     string
+	CacheDirSize int
+}
+
+// Config setting struct to describe a single setting, which may be set by a flag, or an environment variable
+type ConfigSetting struct {
+	Flag   string
+	Usage  string
+	IsBool bool
+	Set    func(c *Config, value string) error
+}
+
+// config is the active configuration of the server. It is set once by main, before the server starts.
+var config = getDefaultConfig()
+
+// getDefaultConfig returns the configuration used when no setting is overridden.
+func getDefaultConfig() Config {
+	return Config{
+		Address:      DEFAULT_ADDRESS,
+		MaxLength:    MAX_LENGTH,
+		MaxBodySize:  DEFAULT_MAX_BODY_SIZE,
+		DefaultStyle: DEFAULT_STYLE,
+		DefaultWidth: DEFAULT_WIDTH,
+		TemplatesDir: TEMPLATES_DIR,
+		StaticDir:    STATIC_DIR,
+		AssetsDir:    ASSETS_DIR,
+		Dev:          DEFAULT_DEV,
+
+		ReadTimeout:     DEFAULT_READ_TIMEOUT,
+		WriteTimeout:    DEFAULT_WRITE_TIMEOUT,
+		IdleTimeout:     DEFAULT_IDLE_TIMEOUT,
+		ShutdownTimeout: DEFAULT_SHUTDOWN_TIMEOUT,
+		MaxConversions:  runtime.NumCPU(),
+
+		RateLimit:      DEFAULT_RATE_LIMIT,
+		RateBurst:      DEFAULT_RATE_BURST,
+		TrustedProxies: slices.Clone(DEFAULT_TRUSTED_PROXIES),
+
+		CacheSize:    DEFAULT_CACHE_SIZE,
+		CacheDir:     DEFAULT_CACHE_DIR,
+		CacheDirSize: DEFAULT_CACHE_DIR_SIZE,
+	}
+}
+
+// setInt returns a setter that parses a value as an integer, and stores it in the field returned by `field`.
+func setInt(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be an integer, got %q", value)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+// setFloat returns a setter that parses a value as a number, and stores it in the field returned by `field`.
+func setFloat(field func(c *Config) *float64) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number, got %q", value)
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+// setBool returns a setter that parses a value as a boolean, and stores it in the field returned by `field`.
+func setBool(field func(c *Config) *bool) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be a boolean, got %q", value)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+// setDuration returns a setter that parses a value as a duration (such as "30s"), and stores it in the field returned by `field`.
+func setDuration(field func(c *Config) *time.Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("must be a duration, such as 30s, got %q", value)
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+// setString returns a setter that stores a value in the field returned by `field`.
+func setString(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+// setList returns a setter that splits a value on commas, and stores the non-empty, trimmed items in the field returned by
+// `field`.
+func setList(field func(c *Config) *[]string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*field(c) = items
+		return nil
+	}
+}
+
+// getConfigSettings returns every setting that may be overridden by a flag or environment variable.
+func getConfigSettings() []ConfigSetting {
+	return []ConfigSetting{
+		{Flag: "address", Usage: "address to listen on, as host:port", Set: setString(func(c *Config) *string { return &c.Address })},
+		{Flag: "max-length", Usage: fmt.Sprintf("maximum width & height of an ASCII, in characters, up to %d", MAX_LENGTH_LIMIT), Set: setInt(func(c *Config) *int { return &c.MaxLength })},
+		{Flag: "max-body-size", Usage: "maximum size of a conversion request body, in bytes", Set: setInt(func(c *Config) *int { return &c.MaxBodySize })},
+		{Flag: "default-style", Usage: "style used when a request does not specify one", Set: setString(func(c *Config) *string { return &c.DefaultStyle })},
+		{Flag: "default-width", Usage: "width used when a request does not specify one", Set: setInt(func(c *Config) *int { return &c.DefaultWidth })},
+		{Flag: "templates-dir", Usage: "directory containing the HTML templates, read in dev mode", Set: setString(func(c *Config) *string { return &c.TemplatesDir })},
+		{Flag: "static-dir", Usage: "directory containing the static files (scripts & styles), read in dev mode", Set: setString(func(c *Config) *string { return &c.StaticDir })},
+		{Flag: "assets-dir", Usage: "directory containing the assets (images & icons), read in dev mode", Set: setString(func(c *Config) *string { return &c.AssetsDir })},
+		{Flag: "dev", Usage: "read templates, static files & assets from disk instead of the binary", IsBool: true, Set: setBool(func(c *Config) *bool { return &c.Dev })},
+		{Flag: "read-timeout", Usage: "maximum duration for reading a request, including the body", Set: setDuration(func(c *Config) *time.Duration { return &c.ReadTimeout })},
+		{Flag: "write-timeout", Usage: "maximum duration for writing a response, including the conversion", Set: setDuration(func(c *Config) *time.Duration { return &c.WriteTimeout })},
+		{Flag: "idle-timeout", Usage: "maximum duration to keep an idle connection open", Set: setDuration(func(c *Config) *time.Duration { return &c.IdleTimeout })},
+		{Flag: "shutdown-timeout", Usage: "maximum duration to wait for active requests to finish on shutdown", Set: setDuration(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+		{Flag: "max-conversions", Usage: "maximum number of largest possible conversions to run at once; smaller conversions take a smaller share", Set: setInt(func(c *Config) *int { return &c.MaxConversions })},
+		{Flag: "rate-limit", Usage: "maximum conversion requests per second from a single client, or 0 to disable rate limiting", Set: setFloat(func(c *Config) *float64 { return &c.RateLimit })},
+		{Flag: "rate-burst", Usage: "maximum conversion requests a single client may make in a burst", Set: setInt(func(c *Config) *int { return &c.RateBurst })},
+		{Flag: "trusted-proxies", Usage: "comma separated IPs & CIDRs of proxies trusted to set the X-Real-IP header", Set: setList(func(c *Config) *[]string { return &c.TrustedProxies })},
+		{Flag: "cache-size", Usage: "maximum size of the in-memory cache of generated ASCIIs, in bytes, or 0 to disable caching", Set: setInt(func(c *Config) *int { return &c.CacheSize })},
+		{Flag: "cache-dir", Usage: "directory of the on-disk cache of generated ASCIIs, or empty to only cache in memory", Set: setString(func(c *Config) *string { return &c.CacheDir })},
+		{Flag: "cache-dir-size", Usage: "maximum size of the on-disk cache, in bytes", Set: setInt(func(c *Config) *int { return &c.CacheDirSize })},
+	}
+}
+
+// getEnvName maps the flag name of a setting to it's environment variable. For example, "max-length" becomes
+// "IMAGE2ASCII_MAX_LENGTH".
+func getEnvName(flagName string) string {
+	return ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// getConfigKey maps the flag name of a setting to it's key in a config file. For example, "max-length" becomes "max_length".
+func getConfigKey(flagName string) string {
+	return strings.ReplaceAll(flagName, "-", "_")
+}
+
+// readConfigFile overrides the settings of c with those defined in the JSON object at `path`, using the same setters as flags &
+// environment variables. Settings missing from the file are left unchanged, and unknown settings are rejected.
+func readConfigFile(c *Config, path string, settings []ConfigSetting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	errs := []error{}
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		raw := values[key]
+		index := slices.IndexFunc(settings, func(setting ConfigSetting) bool { return getConfigKey(setting.Flag) == key })
+		if index < 0 {
+			errs = append(errs, fmt.Errorf("config file %s: unknown setting %q", path, key))
+			continue
+		}
+
+		value := string(raw)
+		var s string
+		var list []string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			value = s
+		} else if err := json.Unmarshal(raw, &list); err == nil {
+			value = strings.Join(list, ",")
+		}
+		if err := settings[index].Set(c, value); err != nil {
+			errs = append(errs, fmt.Errorf("config file %s: %s: %w", path, key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isDir determines whether `path` exists, and is a directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// validateConfig ensures that every setting of c is valid.
+// Returns an error describing every invalid setting, nil otherwise.
+func validateConfig(c Config) error {
+	errs := []error{}
+
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		errs = append(errs, fmt.Errorf("invalid address: %w", err))
+	}
+
+	if c.MaxLength < MIN_LENGTH || c.MaxLength > MAX_LENGTH_LIMIT {
+		errs = append(errs, fmt.Errorf("invalid max length: must be a number between %d & %d", MIN_LENGTH, MAX_LENGTH_LIMIT))
+	}
+
+	if c.MaxBodySize < 1 {
+		errs = append(errs, errors.New("invalid max body size: must be at least 1"))
+	}
+
+	if c.DefaultWidth < MIN_LENGTH || c.DefaultWidth > c.MaxLength {
+		errs = append(errs, fmt.Errorf("invalid default width: must be a number between %d & %d", MIN_LENGTH, c.MaxLength))
+	}
+
+	styles := slices.DeleteFunc(getStyles(), func(style string) bool { return style == STYLE_CUSTOM })
+	if !slices.Contains(styles, c.DefaultStyle) {
+		errs = append(errs, fmt.Errorf("invalid default style: must be one of the following: %s", strings.Join(styles, ", ")))
+	}
+
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{{"read timeout", c.ReadTimeout}, {"write timeout", c.WriteTimeout}, {"idle timeout", c.IdleTimeout}, {"shutdown timeout", c.ShutdownTimeout}}
+	for _, timeout := range timeouts {
+		if timeout.value <= 0 {
+			errs = append(errs, fmt.Errorf("invalid %s: must be a positive duration", timeout.name))
+		}
+	}
+
+	// the conversion semaphore holds the weight of the largest conversion once per allowed conversion
+	length := min(max(c.MaxLength, MIN_LENGTH), MAX_LENGTH_LIMIT)
+	maxWeight := length * length
+	if c.MaxConversions < 1 || c.MaxConversions > math.MaxInt/maxWeight {
+		errs = append(errs, fmt.Errorf("invalid max conversions: must be a number between 1 & %d", math.MaxInt/maxWeight))
+	}
+
+	if c.RateLimit < 0 {
+		errs = append(errs, errors.New("invalid rate limit: must not be negative"))
+	}
+
+	if c.RateBurst < 1 {
+		errs = append(errs, errors.New("invalid rate burst: must be at least 1"))
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("invalid trusted proxy: %s is not an IP or CIDR", proxy))
+		}
+	}
+
+	if c.CacheSize < 0 {
+		errs = append(errs, errors.New("invalid cache size: must not be negative"))
+	}
+
+	if c.CacheDir != "" && c.CacheDirSize < 1 {
+		errs = append(errs, errors.New("invalid cache dir size: must be at least 1"))
+	}
+
+	if c.Dev {
+		dirs := []struct{ name, path string }{{"templates", c.TemplatesDir}, {"static", c.StaticDir}, {"assets", c.AssetsDir}}
+		for _, dir := range dirs {
+			if !isDir(dir.path) {
+				errs = append(errs, fmt.Errorf("invalid %s dir: %s is not a directory", dir.name, dir.path))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// loadConfig builds the configuration of the server from `args` (command line arguments, excluding the program name), and the
+// environment. Settings are applied in order of increasing precedence: defaults, the JSON config file (set by the -config flag,
+// or the IMAGE2ASCII_CONFIG environment variable), environment variables, and finally flags.
+// Returns an error if any setting cannot be parsed, or fails validation.
+func loadConfig(args []string) (Config, error) {
+	settings := getConfigSettings()
+	fs := flag.NewFlagSet("image2ascii", flag.ExitOnError)
+	configPath := fs.String("config", os.Getenv(getEnvName("config")), "path to a JSON config file")
+	for _, setting := range settings {
+		usage := fmt.Sprintf("%s (env %s)", setting.Usage, getEnvName(setting.Flag))
+		if setting.IsBool {
+			fs.Bool(setting.Flag, false, usage)
+		} else {
+			fs.String(setting.Flag, "", usage)
+		}
+	}
+	fs.Parse(args)
+
+	c := getDefaultConfig()
+	if *configPath != "" {
+		if err := readConfigFile(&c, *configPath, settings); err != nil {
+			return c, err
+		}
+	}
+
+	errs := []error{}
+	for _, setting := range settings {
+		if value, ok := os.LookupEnv(getEnvName(setting.Flag)); ok {
+			if err := setting.Set(&c, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", getEnvName(setting.Flag), err))
+			}
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		index := slices.IndexFunc(settings, func(setting ConfigSetting) bool { return setting.Flag == f.Name })
+		if index < 0 {
+			return
+		}
+		if err := settings[index].Set(&c, f.Value.String()); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", f.Name, err))
+		}
+	})
+
+	if err := errors.Join(errs...); err != nil {
+		return c, err
+	}
+
+	return c, validateConfig(c)
+}
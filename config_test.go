@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfigMaxLength(t *testing.T) {
+	cases := []struct {
+		Name      string
+		MaxLength int
+		Valid     bool
+	}{
+		{Name: "default", MaxLength: MAX_LENGTH, Valid: true},
+		{Name: "limit", MaxLength: MAX_LENGTH_LIMIT, Valid: true},
+		{Name: "zero", MaxLength: 0},
+		{Name: "above limit", MaxLength: MAX_LENGTH_LIMIT + 1},
+		{Name: "overflowing", MaxLength: math.MaxInt},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			conf := getDefaultConfig()
+			conf.MaxLength, conf.DefaultWidth = c.MaxLength, MIN_LENGTH
+			err := validateConfig(conf)
+
+			if c.Valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !c.Valid && (err == nil || !strings.Contains(err.Error(), "invalid max length")) {
+				t.Fatalf("expected a max length error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigMaxConversions(t *testing.T) {
+	conf := getDefaultConfig()
+	conf.MaxLength, conf.MaxConversions = MAX_LENGTH_LIMIT, math.MaxInt
+	if err := validateConfig(conf); err == nil || !strings.Contains(err.Error(), "invalid max conversions") {
+		t.Fatalf("expected a max conversions error, got %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import "math"
+
+// Sobel kernels [https://en.wikipedia.org/wiki/Sobel_operator]
+var (
+	sobelX = [3][3]float64{
+		{-1, 0, 1},
+		{-2, 0, 2},
+		{-1, 0, 1},
+	}
+	sobelY = [3][3]float64{
+		{-1, -2, -1},
+		{0, 0, 0},
+		{1, 2, 1},
+	}
+)
+
+// getSobelGradients takes a grayscale matrix, and returns the gradient magnitude and direction (in radians) of each pixel.
+// Magnitudes are normalized such that a hard step from 0.0 to 1.0 has a magnitude of 1.0. Pixels outside the matrix are clamped
+// to the edge. Both matrices are taken from the buffer pool, so they should be released once they are no longer used.
+func getSobelGradients(grayscaleMatrix *LuminanceBuffer) (*LuminanceBuffer, *LuminanceBuffer) {
+	height, width := grayscaleMatrix.Height, grayscaleMatrix.Width
+	magnitudes, directions := getLuminanceBuffer(width, height), getLuminanceBuffer(width, height)
+
+	parallelRows(height, func(y int) {
+		magnitudeRow, directionRow := magnitudes.Row(y), directions.Row(y)
+		for x := range width {
+			gx, gy := 0.0, 0.0
+			for ky := -1; ky <= 1; ky++ {
+				for kx := -1; kx <= 1; kx++ {
+					v := float64(grayscaleMatrix.At(min(max(x+kx, 0), width-1), min(max(y+ky, 0), height-1)))
+					gx += sobelX[ky+1][kx+1] * v
+					gy += sobelY[ky+1][kx+1] * v
+				}
+			}
+
+			magnitudeRow[x] = float32(math.Hypot(gx, gy) / 4.0)
+			directionRow[x] = float32(math.Atan2(gy, gx))
+		}
+	})
+
+	return magnitudes, directions
+}
+
+// getGradientNeighbors returns the relative positions of the two pixels adjacent to a pixel along it's gradient direction,
+// with the direction rounded to the nearest 45 degrees.
+func getGradientNeighbors(direction float64) (RelativePosition, RelativePosition) {
+	angle := math.Mod(direction*180.0/math.Pi+180.0, 180.0)
+
+	switch {
+	case angle < 22.5 || angle >= 157.5:
+		return RelativePosition{Dx: -1, Dy: 0}, RelativePosition{Dx: 1, Dy: 0}
+	case angle < 67.5:
+		return RelativePosition{Dx: -1, Dy: -1}, RelativePosition{Dx: 1, Dy: 1}
+	case angle < 112.5:
+		return RelativePosition{Dx: 0, Dy: -1}, RelativePosition{Dx: 0, Dy: 1}
+	default:
+		return RelativePosition{Dx: 1, Dy: -1}, RelativePosition{Dx: -1, Dy: 1}
+	}
+}
+
+// suppressNonMaximum thins edges by zeroing the magnitude of every pixel that is not a local maximum along it's gradient
+// direction. Returns a new magnitude matrix, taken from the buffer pool.
+func suppressNonMaximum(magnitudes, directions *LuminanceBuffer) *LuminanceBuffer {
+	height, width := magnitudes.Height, magnitudes.Width
+	magnitudeAt := func(x, y int) float32 {
+		if x < 0 || x >= width || y < 0 || y >= height {
+			return 0.0
+		}
+		return magnitudes.At(x, y)
+	}
+
+	suppressed := getLuminanceBuffer(width, height)
+	parallelRows(height, func(y int) {
+		row := suppressed.Row(y)
+		for x := range row {
+			a, b := getGradientNeighbors(float64(directions.At(x, y)))
+			magnitude := magnitudes.At(x, y)
+			if magnitude >= magnitudeAt(x+a.Dx, y+a.Dy) && magnitude >= magnitudeAt(x+b.Dx, y+b.Dy) {
+				row[x] = magnitude
+			}
+		}
+	})
+
+	return suppressed
+}
+
+// isEdgeMagnitude determines whether a gradient magnitude meets `threshold`. A magnitude of 0 is never an edge.
+func isEdgeMagnitude(magnitude float32, threshold float64) bool {
+	return float64(magnitude) >= threshold && magnitude > 0.0
+}
+
+// traceEdges performs hysteresis thresholding: every pixel with a magnitude of at least `high` is an edge, as is every pixel
+// with a magnitude of at least `low` that is connected (8-way) to an edge. The value of edges[y*width+x] is true if the pixel at
+// (x, y) is an edge.
+func traceEdges(magnitudes *LuminanceBuffer, low, high float64) []bool {
+	height, width := magnitudes.Height, magnitudes.Width
+	edges := make([]bool, width*height)
+	stack := []Point{}
+
+	for y := range height {
+		for x, magnitude := range magnitudes.Row(y) {
+			if isEdgeMagnitude(magnitude, high) {
+				edges[y*width+x] = true
+				stack = append(stack, Point{X: x, Y: y})
+			}
+		}
+	}
+
+	for len(stack) > 0 {
+		point := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				x, y := point.X+dx, point.Y+dy
+				if x < 0 || x >= width || y < 0 || y >= height || edges[y*width+x] {
+					continue
+				}
+				if isEdgeMagnitude(magnitudes.At(x, y), low) {
+					edges[y*width+x] = true
+					stack = append(stack, Point{X: x, Y: y})
+				}
+			}
+		}
+	}
+
+	return edges
+}
+
+// detectEdges takes a grayscale matrix, and returns a slice with an element per pixel, where edges[y*width+x] is true if the
+// pixel at (x, y) lies on an edge. `low` and `high` are gradient magnitude thresholds between 0.0 and 1.0.
+// Sobel marks every pixel whose gradient magnitude is at least `high`; `low` is ignored.
+// Canny thins the Sobel gradients to single pixel lines, and uses both thresholds for hysteresis.
+// For more information, see: https://en.wikipedia.org/wiki/Canny_edge_detector
+func detectEdges(grayscaleMatrix *LuminanceBuffer, method string, low, high float64) []bool {
+	magnitudes, directions := getSobelGradients(grayscaleMatrix)
+	defer releaseLuminanceBuffer(magnitudes)
+	defer releaseLuminanceBuffer(directions)
+
+	if method == EDGE_CANNY {
+		suppressed := suppressNonMaximum(magnitudes, directions)
+		defer releaseLuminanceBuffer(suppressed)
+		return traceEdges(suppressed, low, high)
+	}
+
+	width := magnitudes.Width
+	edges := make([]bool, width*magnitudes.Height)
+	parallelRows(magnitudes.Height, func(y int) {
+		for x, magnitude := range magnitudes.Row(y) {
+			edges[y*width+x] = isEdgeMagnitude(magnitude, high)
+		}
+	})
+	return edges
+}
+
+// drawEdges replaces the tone of `grayscaleMatrix` with line art, in place. Edges are detected on the percieved brightness of
+// the (optionally blurred) image, and become fully dark. Every other pixel becomes fully bright, unless `fill` is set, in which
+// case it keeps a faint version of it's original tone, to be dithered.
+// Form is expected to be validated before calling this function.
+func drawEdges(grayscaleMatrix *LuminanceBuffer, form FormData) {
+	width, height := grayscaleMatrix.Width, grayscaleMatrix.Height
+	lightness := getLuminanceBuffer(width, height)
+	parallelRows(height, func(y int) {
+		row := lightness.Row(y)
+		for x, v := range grayscaleMatrix.Row(y) {
+			row[x] = float32(getPercievedBrightness(float64(v)) / 100.0)
+		}
+	})
+
+	blurred := gaussianBlur(lightness, *form.Blur)
+	releaseLuminanceBuffer(lightness)
+	edges := detectEdges(blurred, *form.EdgeMethod, *form.EdgeLow/MAX_EDGE, *form.EdgeHigh/MAX_EDGE)
+	releaseLuminanceBuffer(blurred)
+	fill := *form.Fill / MAX_FILL
+
+	parallelRows(height, func(y int) {
+		row := grayscaleMatrix.Row(y)
+		for x, v := range row {
+			if edges[y*width+x] {
+				row[x] = 0.0
+			} else {
+				row[x] = float32(1.0 - fill*(1.0-float64(v)))
+			}
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// names of the embedded directories
+const (
+	TEMPLATES_DIR = "templates"
+	STATIC_DIR    = "static"
+	ASSETS_DIR    = "assets"
+)
+
+// embeddedFiles holds the templates, static files & assets of the web client, so that the binary can run from any directory.
+// Note that static/styles.css is generated by Tailwind, so it must be built before the binary in order to be embedded.
+//
+//go:embed templates static assets
+var embeddedFiles embed.FS
+
+// File system struct that wraps a http.FileSystem, hiding directories so that their contents are never listed
+type FilesOnlyFS struct {
+	http.FileSystem
+}
+
+func (fsys FilesOnlyFS) Open(name string) (http.File, error) {
+	f, err := fsys.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+	return f, nil
+}
+
+// getFileSystem returns the file system of one of the web client directories. In dev mode, files are read from `path` on disk,
+// so that edits are served without rebuilding. Otherwise, files are read from the embedded directory `name`.
+func getFileSystem(name, path string) fs.FS {
+	if config.Dev {
+		return os.DirFS(path)
+	}
+
+	fsys, err := fs.Sub(embeddedFiles, name)
+	if err != nil {
+		panic("files: failed to open embedded directory " + name + ": " + err.Error())
+	}
+	return fsys
+}
+
+// loadTemplates loads the HTML templates of the web client into router. In dev mode, templates are read from disk, and since
+// gin reloads templates on each request in debug mode, edits to them are reflected immediately.
+func loadTemplates(router *gin.Engine) {
+	if config.Dev {
+		router.LoadHTMLGlob(filepath.Join(config.TemplatesDir, "*"))
+		return
+	}
+
+	templates := template.Must(template.New("").Funcs(router.FuncMap).ParseFS(getFileSystem(TEMPLATES_DIR, config.TemplatesDir), "*"))
+	router.SetHTMLTemplate(templates)
+}
+
+// serveFiles registers the routes that serve the static files & assets of the web client.
+func serveFiles(router *gin.Engine) {
+	static := FilesOnlyFS{http.FS(getFileSystem(STATIC_DIR, config.StaticDir))}
+	assets := FilesOnlyFS{http.FS(getFileSystem(ASSETS_DIR, config.AssetsDir))}
+
+	router.StaticFS("/static", static)
+	router.StaticFS("/assets", assets)
+
+	// Favicons
+	router.StaticFileFS("/favicon.ico", "favicon.ico", assets)
+	router.StaticFileFS("/icon.svg", "icon.svg", assets)
+	router.StaticFileFS("/apple-touch-icon.png", "apple-touch-icon.png", assets)
+	router.StaticFileFS("/og-image.png", "og-image.png", assets)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"math"
+	"slices"
+)
+
+// number of samples along each axis of a halftone cell, used to rank spot function values
+const SPOT_SAMPLES = 64
+
+// getEuclideanSpot is the Euclidean dot spot function, which maps a position within a halftone cell, with both coordinates
+// between -1.0 and 1.0, to a value between -1.0 and 1.0. Values are highest at the center of the cell, so dots grow outwards
+// from the center, and join into a checkerboard pattern at 50% coverage.
+// For more information, see: https://en.wikipedia.org/wiki/Halftone
+func getEuclideanSpot(x, y float64) float64 {
+	ax, ay := math.Abs(x), math.Abs(y)
+	if ax+ay > 1.0 {
+		return (ax-1.0)*(ax-1.0) + (ay-1.0)*(ay-1.0) - 1.0
+	}
+	return 1.0 - (ax*ax + ay*ay)
+}
+
+// getSpotSamples samples the spot function over a grid of `SPOT_SAMPLES` x `SPOT_SAMPLES` positions of a halftone cell, and
+// returns the samples in ascending order.
+func getSpotSamples() []float64 {
+	samples := make([]float64, 0, SPOT_SAMPLES*SPOT_SAMPLES)
+	for y := 0; y < SPOT_SAMPLES; y++ {
+		for x := 0; x < SPOT_SAMPLES; x++ {
+			u, v := 2.0*(float64(x)+0.5)/SPOT_SAMPLES-1.0, 2.0*(float64(y)+0.5)/SPOT_SAMPLES-1.0
+			samples = append(samples, getEuclideanSpot(u, v))
+		}
+	}
+	slices.Sort(samples)
+
+	return samples
+}
+
+// spotSamples holds the sorted spot function samples of a halftone cell
+var spotSamples = getSpotSamples()
+
+// getSpotRank maps a spot function value to the fraction of the cell with a lower value, a number between 0.0 and 1.0.
+// Since the spot function does not cover the cell evenly, thresholding the raw values would distort tones. Using the rank
+// instead ensures that a pixel with luminance v covers a (1 - v) fraction of each cell.
+func getSpotRank(spot float64) float64 {
+	rank, _ := slices.BinarySearch(spotSamples, spot)
+	return float64(rank) / float64(len(spotSamples))
+}
+
+// getHalftoneThresholdMap returns a threshold map that clusters pixels into round dots, laid out on a grid of square cells
+// with sides of `cellSize` pixels, rotated by `angle` degrees.
+// For more information, see: https://en.wikipedia.org/wiki/Halftone#Multiple_screens_and_color_halftoning
+func getHalftoneThresholdMap(cellSize int, angle float64) ThresholdMap {
+	sin, cos := math.Sincos(angle * math.Pi / 180.0)
+	size := float64(cellSize)
+
+	return func(point Point) float64 {
+		x, y := float64(point.X)+0.5, float64(point.Y)+0.5
+		u, v := (x*cos+y*sin)/size, (y*cos-x*sin)/size
+		return getSpotRank(getEuclideanSpot(2.0*(u-math.Floor(u))-1.0, 2.0*(v-math.Floor(v))-1.0))
+	}
+}
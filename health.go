@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// health statuses
+const (
+	STATUS_OK          = "ok"
+	STATUS_UNAVAILABLE = "unavailable"
+)
+
+// Health response struct, returned by the liveness & readiness endpoints
+type HealthResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Version response struct, describing the build of the running binary
+type VersionResponse struct {
+	Module    string `json:"module"`
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified"`
+}
+
+// build info injected at build time, e.g. go build -ldflags "-X main.version=... -X main.commit=... -X main.buildTime=...".
+// Deployed builds are made without the git repository, so the toolchain cannot embed the VCS info itself.
+var (
+	version   string
+	commit    string
+	buildTime string
+)
+
+// isShuttingDown is set once the server begins draining, so that readiness checks fail while active requests finish.
+var isShuttingDown atomic.Bool
+
+// getSelfTestImage returns a small image, black on the left half and white on the right half.
+func getSelfTestImage() image.Image {
+	img := image.NewGray(image.Rect(0, 0, 2*CHAR_WIDTH, CHAR_HEIGHT))
+	for y := range CHAR_HEIGHT {
+		for x := CHAR_WIDTH; x < 2*CHAR_WIDTH; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	return img
+}
+
+// selfTest converts a tiny image through the same path as a request, using the default settings.
+// Returns an error if the conversion fails, or does not produce the expected output, nil otherwise.
+func selfTest() error {
+	img := getSelfTestImage()
+	width, height := 2, 1
+	form := FormData{Width: &width, Height: &height}
+
+	if err := validateFormData(&form, img.Bounds()); err != nil {
+		return err
+	}
+
+	encodingSettings, err := getEncodingSettings(form)
+	if err != nil {
+		return err
+	}
+
+	ascii := generateAscii(img, form, encodingSettings)
+	if len(ascii) != height || utf8.RuneCountInString(ascii[0]) != width {
+		return errors.New("self test: output has the wrong dimensions")
+	}
+
+	runes := []rune(ascii[0])
+	if runes[0] == runes[1] {
+		return errors.New("self test: black & white halves produced the same character")
+	}
+
+	return nil
+}
+
+// getHealthz is the function executed when a user does a GET request to "/healthz".
+// This simply reports that the process is alive, and able to serve requests.
+func getHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{Status: STATUS_OK})
+}
+
+// getReadyz is the function executed when a user does a GET request to "/readyz".
+// Reports that the server is ready if it is not shutting down, and a self test conversion succeeds. Otherwise, responds
+// with a 503, and the reason.
+func getReadyz(c *gin.Context) {
+	if isShuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, HealthResponse{Status: STATUS_UNAVAILABLE, Error: "server is shutting down"})
+		return
+	}
+
+	if err := selfTest(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, HealthResponse{Status: STATUS_UNAVAILABLE, Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, HealthResponse{Status: STATUS_OK})
+}
+
+// getBuildVersion returns the build info of the running binary. Values injected at build time take precedence, and the build
+// info embedded by the go toolchain is used as a fallback.
+func getBuildVersion() VersionResponse {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		info = &debug.BuildInfo{Main: debug.Module{Version: "unknown"}}
+	}
+
+	buildVersion := VersionResponse{
+		Module:    info.Main.Path,
+		Version:   info.Main.Version,
+		GoVersion: info.GoVersion,
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			buildVersion.Revision = setting.Value
+		case "vcs.time":
+			buildVersion.Time = setting.Value
+		case "vcs.modified":
+			buildVersion.Modified = setting.Value == "true"
+		}
+	}
+
+	if version != "" {
+		buildVersion.Version = version
+	}
+	if commit != "" {
+		buildVersion.Revision = commit
+		buildVersion.Modified = false
+	}
+	if buildTime != "" {
+		buildVersion.Time = buildTime
+	}
+
+	return buildVersion
+}
+
+// getVersion is the function executed when a user does a GET request to "/version".
+// This simply returns the build info of the running binary.
+func getVersion(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, getBuildVersion())
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// seconds a client is asked to wait before retrying a request rejected by the conversion limit
+const CONVERSION_RETRY_AFTER = "1"
+
+// conversions smaller than 1/CONVERSION_MIN_WEIGHT_DIVISOR of the largest possible ASCII are weighted as if they were that
+// size, since reading & decoding the image has a cost regardless of the size of the output
+const CONVERSION_MIN_WEIGHT_DIVISOR = 16
+
+// how often idle clients are removed from the rate limiter
+const RATE_LIMIT_SWEEP_INTERVAL = time.Minute
+
+// gin context key, holding the conversion slot of a request
+const CONTEXT_CONVERSION_SLOT_KEY = "conversionSlot"
+
+// Token bucket struct to track the remaining requests of a single client
+type TokenBucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Rate limiter struct that limits each client to a steady rate of requests, with short bursts, using a token bucket per
+// client IP
+type RateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*TokenBucket
+	lastSweep time.Time
+}
+
+// newRateLimiter returns a rate limiter allowing each client `rate` requests per second, and bursts of up to `burst` requests.
+func newRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   map[string]*TokenBucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// refill adds the tokens earned by bucket since it was last updated, up to the burst size.
+func (l *RateLimiter) refill(bucket *TokenBucket, now time.Time) {
+	bucket.Tokens = math.Min(l.burst, bucket.Tokens+now.Sub(bucket.Updated).Seconds()*l.rate)
+	bucket.Updated = now
+}
+
+// sweep removes the buckets of clients that have been idle long enough for their bucket to refill, since they are
+// indistinguishable from new clients. Must be called with the lock held.
+func (l *RateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		l.refill(bucket, now)
+		if bucket.Tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Allow takes a token from the bucket of client `key`.
+// Returns true if the bucket had a token, otherwise false, and how long until the next token is available.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= RATE_LIMIT_SWEEP_INTERVAL {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &TokenBucket{Tokens: l.burst, Updated: now}
+		l.buckets[key] = bucket
+	}
+
+	l.refill(bucket, now)
+	if bucket.Tokens < 1 {
+		wait := time.Duration((1 - bucket.Tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+
+	bucket.Tokens--
+	return true, 0
+}
+
+// Weighted semaphore struct that limits the total weight of the conversions running at once
+type WeightedSemaphore struct {
+	mu       sync.Mutex
+	capacity int
+	used     int
+}
+
+// newWeightedSemaphore returns a semaphore allowing a total weight of `capacity` to be held at once.
+func newWeightedSemaphore(capacity int) *WeightedSemaphore {
+	return &WeightedSemaphore{capacity: capacity}
+}
+
+// TryAcquire attempts to acquire `weight` from s, without blocking.
+// Returns true if the weight was acquired, false otherwise.
+func (s *WeightedSemaphore) TryAcquire(weight int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.used+weight > s.capacity {
+		return false
+	}
+	s.used += weight
+	return true
+}
+
+// Release returns `weight` to s.
+func (s *WeightedSemaphore) Release(weight int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used -= weight
+}
+
+// Conversion slot struct to track the weight a single request holds on the conversion semaphore
+type ConversionSlot struct {
+	semaphore *WeightedSemaphore
+	weight    int
+}
+
+// getMaxConversionWeight returns the weight of the largest possible conversion.
+func getMaxConversionWeight() int {
+	return config.MaxLength * config.MaxLength
+}
+
+// getMinConversionWeight returns the weight held by every conversion, while it's image is read & decoded.
+func getMinConversionWeight() int {
+	return max(1, getMaxConversionWeight()/CONVERSION_MIN_WEIGHT_DIVISOR)
+}
+
+// getConversionWeight returns the weight of a conversion, based on the number of characters it outputs.
+func getConversionWeight(form FormData) int {
+	return max(getMinConversionWeight(), *form.Width**form.Height)
+}
+
+// rejectBusy responds to the request handled by c with a 429, asking the client to retry shortly.
+func rejectBusy(c *gin.Context) {
+	c.Header("Retry-After", CONVERSION_RETRY_AFTER)
+	respondWithError(c, http.StatusTooManyRequests, ErrorResponse{Error: "server busy: too many conversions in progress, try again shortly"})
+}
+
+// limitRate returns a middleware that limits each client to the configured rate of requests, rejecting requests beyond it with
+// a 429, and a Retry-After header with the number of seconds until the client may retry. Clients are identified by IP, which is
+// taken from the X-Real-IP header only when the request comes from a trusted proxy.
+func limitRate() gin.HandlerFunc {
+	if config.RateLimit <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	limiter := newRateLimiter(config.RateLimit, config.RateBurst)
+	return func(c *gin.Context) {
+		if ok, wait := limiter.Allow(c.ClientIP()); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			respondWithError(c, http.StatusTooManyRequests, ErrorResponse{Error: "rate limit exceeded: too many requests, try again later"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// limitConversions returns a middleware that limits the total weight of the conversions running at once to the weight of
+// `max` of the largest possible conversions. Each request holds the minimum weight while it's image is read & decoded, and
+// getAscii acquires the rest once the size of the output is known. Requests beyond the limit are rejected immediately with a
+// 429, rather than queued, so that a burst of large requests cannot exhaust the server.
+func limitConversions(max int) gin.HandlerFunc {
+	semaphore := newWeightedSemaphore(max * getMaxConversionWeight())
+
+	return func(c *gin.Context) {
+		slot := &ConversionSlot{semaphore: semaphore, weight: getMinConversionWeight()}
+		if !semaphore.TryAcquire(slot.weight) {
+			rejectBusy(c)
+			return
+		}
+		defer func() { semaphore.Release(slot.weight) }()
+
+		c.Set(CONTEXT_CONVERSION_SLOT_KEY, slot)
+		c.Next()
+	}
+}
+
+// acquireConversionWeight grows the weight held by the request handled by c to `weight`.
+// Returns true if the weight was acquired, or the request is not limited, false otherwise.
+func acquireConversionWeight(c *gin.Context, weight int) bool {
+	value, ok := c.Get(CONTEXT_CONVERSION_SLOT_KEY)
+	if !ok {
+		return true
+	}
+
+	slot := value.(*ConversionSlot)
+	if weight <= slot.weight {
+		return true
+	}
+	if !slot.semaphore.TryAcquire(weight - slot.weight) {
+		return false
+	}
+	slot.weight = weight
+	return true
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimiterRefill(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Tokens   float64
+		Elapsed  time.Duration
+		Expected float64
+	}{
+		{Name: "no time elapsed", Tokens: 1, Elapsed: 0, Expected: 1},
+		{Name: "partial token", Tokens: 0, Elapsed: 250 * time.Millisecond, Expected: 0.5},
+		{Name: "whole tokens", Tokens: 0.5, Elapsed: time.Second, Expected: 2.5},
+		{Name: "capped at burst", Tokens: 1, Elapsed: time.Minute, Expected: 5},
+		{Name: "already full", Tokens: 5, Elapsed: time.Second, Expected: 5},
+	}
+
+	limiter := newRateLimiter(2, 5)
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			updated := time.Now()
+			bucket := &TokenBucket{Tokens: c.Tokens, Updated: updated}
+			limiter.refill(bucket, updated.Add(c.Elapsed))
+
+			if bucket.Tokens != c.Expected {
+				t.Errorf("expected %v tokens, got %v", c.Expected, bucket.Tokens)
+			}
+			if !bucket.Updated.Equal(updated.Add(c.Elapsed)) {
+				t.Errorf("expected bucket to be updated at %v, got %v", updated.Add(c.Elapsed), bucket.Updated)
+			}
+		})
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	limiter := newRateLimiter(0.5, 2)
+	for i := range 2 {
+		if ok, _ := limiter.Allow("a"); !ok {
+			t.Fatalf("request %d: expected to be allowed within the burst", i)
+		}
+	}
+
+	ok, wait := limiter.Allow("a")
+	if ok {
+		t.Fatal("expected request beyond the burst to be rejected")
+	}
+	if wait <= time.Second || wait > 2*time.Second {
+		t.Errorf("expected a wait of up to 2s, got %v", wait)
+	}
+
+	if ok, _ := limiter.Allow("b"); !ok {
+		t.Error("expected another client to have it's own bucket")
+	}
+}
+
+func TestWeightedSemaphore(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Held     int
+		Weight   int
+		Expected bool
+	}{
+		{Name: "empty", Held: 0, Weight: 4, Expected: true},
+		{Name: "fills capacity", Held: 6, Weight: 4, Expected: true},
+		{Name: "exceeds remaining", Held: 7, Weight: 4, Expected: false},
+		{Name: "exceeds capacity", Held: 0, Weight: 11, Expected: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			semaphore := newWeightedSemaphore(10)
+			if !semaphore.TryAcquire(c.Held) {
+				t.Fatalf("failed to acquire %d", c.Held)
+			}
+
+			if ok := semaphore.TryAcquire(c.Weight); ok != c.Expected {
+				t.Fatalf("expected acquiring %d with %d held to return %v", c.Weight, c.Held, c.Expected)
+			}
+			expected := c.Held
+			if c.Expected {
+				expected += c.Weight
+			}
+			if semaphore.used != expected {
+				t.Errorf("expected %d to be held, got %d", expected, semaphore.used)
+			}
+		})
+	}
+
+	semaphore := newWeightedSemaphore(10)
+	semaphore.TryAcquire(10)
+	semaphore.Release(4)
+	if !semaphore.TryAcquire(4) {
+		t.Error("expected released weight to be available again")
+	}
+}
+
+func TestAcquireConversionWeight(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Weight   int
+		Expected bool
+		Held     int
+	}{
+		{Name: "smaller than slot", Weight: 2, Expected: true, Held: 4},
+		{Name: "grows slot", Weight: 8, Expected: true, Held: 8},
+		{Name: "fills capacity", Weight: 10, Expected: true, Held: 10},
+		{Name: "exceeds capacity", Weight: 11, Expected: false, Held: 4},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			semaphore := newWeightedSemaphore(10)
+			semaphore.TryAcquire(4)
+			slot := &ConversionSlot{semaphore: semaphore, weight: 4}
+
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Set(CONTEXT_CONVERSION_SLOT_KEY, slot)
+			if ok := acquireConversionWeight(ctx, c.Weight); ok != c.Expected {
+				t.Fatalf("expected acquiring %d to return %v", c.Weight, c.Expected)
+			}
+			if slot.weight != c.Held || semaphore.used != c.Held {
+				t.Errorf("expected %d to be held, got %d by the slot, and %d by the semaphore", c.Held, slot.weight, semaphore.used)
+			}
+		})
+	}
+
+	t.Run("unlimited", func(t *testing.T) {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		if !acquireConversionWeight(ctx, 1<<30) {
+			t.Error("expected a request without a slot to be allowed")
+		}
+	})
+}
+
+// serveLimited sends `n` requests to a router serving `handlers`, and returns the responses.
+func serveLimited(n int, handlers ...gin.HandlerFunc) []*httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api", handlers...)
+
+	responses := make([]*httptest.ResponseRecorder, n)
+	for i := range responses {
+		responses[i] = httptest.NewRecorder()
+		router.ServeHTTP(responses[i], httptest.NewRequest(http.MethodPost, "/api", nil))
+	}
+	return responses
+}
+
+func TestLimitRate(t *testing.T) {
+	setConfig(t, func(c *Config) { c.RateLimit, c.RateBurst = 0.5, 2 })
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	responses := serveLimited(3, limitRate(), ok)
+	for i, expected := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if responses[i].Code != expected {
+			t.Errorf("request %d: expected status %d, got %d", i, expected, responses[i].Code)
+		}
+	}
+	if retryAfter := responses[2].Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("expected Retry-After of 2, got %q", retryAfter)
+	}
+}
+
+func TestLimitConversions(t *testing.T) {
+	setConfig(t, func(c *Config) { c.MaxLength = 40 })
+	convert := func(weight int) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			if !acquireConversionWeight(c, weight) {
+				rejectBusy(c)
+				return
+			}
+			c.Status(http.StatusOK)
+		}
+	}
+
+	cases := []struct {
+		Name     string
+		Weight   int
+		Expected int
+	}{
+		{Name: "largest conversion", Weight: getMaxConversionWeight(), Expected: http.StatusOK},
+		{Name: "beyond capacity", Weight: getMaxConversionWeight() + 1, Expected: http.StatusTooManyRequests},
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			response := serveLimited(1, limitConversions(1), convert(c.Weight))[0]
+			if response.Code != c.Expected {
+				t.Fatalf("expected status %d, got %d", c.Expected, response.Code)
+			}
+			if c.Expected == http.StatusTooManyRequests && response.Header().Get("Retry-After") != CONVERSION_RETRY_AFTER {
+				t.Errorf("expected Retry-After of %s, got %q", CONVERSION_RETRY_AFTER, response.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// header used to receive & echo the ID of a request
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+// number of random bytes in a generated request ID
+const REQUEST_ID_BYTES = 8
+
+// gin context keys, set while handling a request so that the request logger can describe it
+const (
+	CONTEXT_REQUEST_ID_KEY = "requestId"
+	CONTEXT_ERROR_KEY      = "error"
+)
+
+// request IDs received from clients or proxies are only trusted if they match this pattern, so that arbitrary input is never
+// written to the logs
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// newLogger returns a logger that writes JSON records to stdout.
+func newLogger() *slog.Logger {
+	return slog.New(slog.NewJSONHandler(os.Stdout, nil))
+}
+
+// newRequestID returns a random, hex encoded request ID.
+func newRequestID() string {
+	b := make([]byte, REQUEST_ID_BYTES)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// getRequestID returns the ID of the request handled by c.
+func getRequestID(c *gin.Context) string {
+	return c.GetString(CONTEXT_REQUEST_ID_KEY)
+}
+
+// getLogger returns the default logger, tagged with the ID of the request handled by c.
+func getLogger(c *gin.Context) *slog.Logger {
+	return slog.Default().With(slog.String("request_id", getRequestID(c)))
+}
+
+// getMilliseconds converts d to a number of milliseconds, with microsecond precision.
+func getMilliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000.0
+}
+
+// respondWithError aborts the request handled by c, responding with `status`, and response tagged with the ID of the request.
+func respondWithError(c *gin.Context, status int, response ErrorResponse) {
+	response.RequestID = getRequestID(c)
+	c.Set(CONTEXT_ERROR_KEY, response.Error)
+	c.Abort()
+	c.IndentedJSON(status, response)
+}
+
+// logRequests returns a middleware that assigns each request an ID, and logs each request once it has been handled. The ID is
+// taken from the X-Request-ID header when it is valid (so that IDs set by a proxy carry through), generated otherwise, and
+// echoed in the X-Request-ID response header.
+func logRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(REQUEST_ID_HEADER)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set(CONTEXT_REQUEST_ID_KEY, id)
+		c.Header(REQUEST_ID_HEADER, id)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", getMilliseconds(time.Since(start))),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		}
+		if err := c.GetString(CONTEXT_ERROR_KEY); err != "" {
+			attrs = append(attrs, slog.String("error", err))
+		}
+
+		getLogger(c).LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// recoverRequests returns a middleware that recovers from a panic while handling a request, logging the panic & stack trace,
+// and responding with a 500.
+func recoverRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				getLogger(c).Error("panic", slog.Any("error", err), slog.String("stack", string(debug.Stack())))
+				respondWithError(c, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+			}
+		}()
+		c.Next()
+	}
+}
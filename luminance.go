@@ -0,0 +1,64 @@
+package main
+
+import "sync"
+
+// Luminance buffer struct, holding a matrix of luminance values (or any other per-pixel values, such as gradients) in a single
+// contiguous slice, rather than one slice per row. The value at (x, y) is Pix[y*Stride+x].
+type LuminanceBuffer struct {
+	Pix    []float32
+	Width  int
+	Height int
+	Stride int
+}
+
+// At returns the value at (x, y).
+func (b *LuminanceBuffer) At(x, y int) float32 {
+	return b.Pix[y*b.Stride+x]
+}
+
+// Set sets the value at (x, y) to v.
+func (b *LuminanceBuffer) Set(x, y int, v float32) {
+	b.Pix[y*b.Stride+x] = v
+}
+
+// Row returns the values of row y. The returned slice shares memory with the buffer.
+func (b *LuminanceBuffer) Row(y int) []float32 {
+	start := y * b.Stride
+	return b.Pix[start : start+b.Width : start+b.Width]
+}
+
+// luminanceBufferPool holds buffers released by finished conversions, so that their memory is reused by later conversions
+// rather than reallocated, which reduces pressure on the garbage collector under load.
+var luminanceBufferPool = sync.Pool{
+	New: func() any { return &LuminanceBuffer{} },
+}
+
+// getLuminanceBuffer returns a zeroed buffer with dimensions `width` x `height`, reusing the memory of a released buffer when one
+// is large enough. The buffer should be released with releaseLuminanceBuffer once it is no longer used.
+func getLuminanceBuffer(width, height int) *LuminanceBuffer {
+	b := luminanceBufferPool.Get().(*LuminanceBuffer)
+	size := width * height
+	if cap(b.Pix) < size {
+		b.Pix = make([]float32, size)
+	} else {
+		b.Pix = b.Pix[:size]
+		clear(b.Pix)
+	}
+	b.Width, b.Height, b.Stride = width, height, width
+
+	return b
+}
+
+// cloneLuminanceBuffer returns a copy of b, from the pool.
+func cloneLuminanceBuffer(b *LuminanceBuffer) *LuminanceBuffer {
+	clone := getLuminanceBuffer(b.Width, b.Height)
+	for y := range b.Height {
+		copy(clone.Row(y), b.Row(y))
+	}
+	return clone
+}
+
+// releaseLuminanceBuffer returns b to the pool. b must not be used after it is released.
+func releaseLuminanceBuffer(b *LuminanceBuffer) {
+	luminanceBufferPool.Put(b)
+}
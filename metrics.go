@@ -0,0 +1,139 @@
+package main
+
+import (
+	"image"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespace of every metric exported by the server
+const METRICS_NAMESPACE = "image2ascii"
+
+// label value used when a request fails before it's style or format is known, or either is invalid
+const LABEL_UNKNOWN = "unknown"
+
+// gin context keys, set by getAscii so that the metrics middleware can label each request
+const (
+	CONTEXT_STYLE_KEY  = "style"
+	CONTEXT_FORMAT_KEY = "format"
+)
+
+// conversion metrics
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "requests_total",
+		Help:      "Total number of conversion requests, by style, output format and status code.",
+	}, []string{"style", "format", "status"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "request_duration_seconds",
+		Help:      "Duration of conversion requests, including reading & decoding the image, by status code.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"status"})
+
+	conversionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "conversion_duration_seconds",
+		Help:      "Duration of generating an ASCII from a decoded image, by style.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"style"})
+
+	imagePixels = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "image_pixels",
+		Help:      "Number of pixels in each decoded image.",
+		Buckets:   prometheus.ExponentialBuckets(1024, 4, 10),
+	})
+
+	outputCharacters = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "output_characters",
+		Help:      "Number of characters in each generated ASCII.",
+		Buckets:   prometheus.ExponentialBuckets(16, 4, 9),
+	})
+
+	cacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "cache_requests_total",
+		Help:      "Total number of cache lookups, by result.",
+	}, []string{"result"})
+
+	conversionsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "conversions_in_flight",
+		Help:      "Number of ASCIIs currently being generated.",
+	})
+)
+
+// getLabel returns the value stored in c under `key` if it is one of `valid`, and `LABEL_UNKNOWN` otherwise, so that user
+// input can never create new label values.
+func getLabel(c *gin.Context, key string, valid []string) string {
+	value := c.GetString(key)
+	if !slices.Contains(valid, value) {
+		return LABEL_UNKNOWN
+	}
+	return value
+}
+
+// setMetricLabels stores the style & format of form in c, so that the metrics middleware can label the request.
+func setMetricLabels(c *gin.Context, form FormData) {
+	if form.Style != nil {
+		c.Set(CONTEXT_STYLE_KEY, *form.Style)
+	}
+	if form.Format != nil {
+		c.Set(CONTEXT_FORMAT_KEY, *form.Format)
+	}
+}
+
+// instrumentRequests returns a middleware that records the count & duration of each conversion request.
+func instrumentRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := strconv.Itoa(c.Writer.Status())
+		requestsTotal.WithLabelValues(
+			getLabel(c, CONTEXT_STYLE_KEY, getStyles()),
+			getLabel(c, CONTEXT_FORMAT_KEY, getFormats()),
+			status,
+		).Inc()
+		requestDuration.WithLabelValues(status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// observeImage records the size of an image that was decoded to be converted, given it's bounds.
+func observeImage(bounds image.Rectangle) {
+	imagePixels.Observe(float64(bounds.Dx() * bounds.Dy()))
+}
+
+// observeCache records the result of a cache lookup.
+func observeCache(result string) {
+	cacheRequestsTotal.WithLabelValues(result).Inc()
+}
+
+// generateAsciiInstrumented calls generateAscii, recording the duration of the conversion, the number of characters it
+// produces, and the number of conversions in flight.
+func generateAsciiInstrumented(img image.Image, form FormData, encodingSettings EncodingSettings) []string {
+	conversionsInFlight.Inc()
+	defer conversionsInFlight.Dec()
+
+	start := time.Now()
+	ascii := generateAscii(img, form, encodingSettings)
+	conversionDuration.WithLabelValues(*form.Style).Observe(time.Since(start).Seconds())
+
+	characters := 0
+	for _, row := range ascii {
+		characters += utf8.RuneCountInString(row)
+	}
+	outputCharacters.Observe(float64(characters))
+
+	return ascii
+}
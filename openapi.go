@@ -0,0 +1,314 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// version of the API, as reported by the OpenAPI document
+const API_VERSION = "1.0.0"
+
+// path prefix of the versioned API routes
+const API_V1_PREFIX = "/api/v1"
+
+// prefix of a reference to a schema in the components section of the OpenAPI document
+const SCHEMA_REF_PREFIX = "#/components/schemas/"
+
+// getFieldConstraints returns the OpenAPI keywords (enum, bounds & defaults) of each form field that has them, keyed by
+// form field name. These are applied on top of the schema generated from the FormData struct.
+func getFieldConstraints() map[string]map[string]any {
+	return map[string]map[string]any{
+		FORM_THEME_NAME:             {"enum": getThemes(), "default": DEFAULT_THEME},
+		FORM_WIDTH_NAME:             {"minimum": MIN_LENGTH, "maximum": config.MaxLength, "default": config.DefaultWidth},
+		FORM_HEIGHT_NAME:            {"minimum": MIN_LENGTH, "maximum": config.MaxLength, "description": "Computed from width & the aspect ratio of the image when unset."},
+		FORM_INVERT_NAME:            {"default": DEFAULT_INVERTED},
+		FORM_EXPOSURE_NAME:          {"minimum": MIN_EXPOSURE, "maximum": MAX_EXPOSURE, "default": DEFAULT_EXPOSURE},
+		FORM_STYLE_NAME:             {"enum": getStyles(), "default": config.DefaultStyle},
+		FORM_BRIGHTNESS_NAME:        {"minimum": MIN_BRIGHTNESS, "maximum": MAX_BRIGHTNESS, "default": DEFAULT_BRIGHTNESS},
+		FORM_CONTRAST_NAME:          {"minimum": MIN_CONTRAST, "maximum": MAX_CONTRAST, "default": DEFAULT_CONTRAST},
+		FORM_GAMMA_NAME:             {"minimum": MIN_GAMMA, "maximum": MAX_GAMMA, "default": DEFAULT_GAMMA},
+		FORM_BLACK_POINT_NAME:       {"minimum": MIN_LEVEL, "maximum": MAX_LEVEL, "default": DEFAULT_BLACK_POINT},
+		FORM_WHITE_POINT_NAME:       {"minimum": MIN_LEVEL, "maximum": MAX_LEVEL, "default": DEFAULT_WHITE_POINT},
+		FORM_EQUALIZE_NAME:          {"enum": getEqualizations(), "default": DEFAULT_EQUALIZE},
+		FORM_TILE_SIZE_NAME:         {"minimum": MIN_TILE_SIZE, "maximum": MAX_TILE_SIZE, "default": DEFAULT_TILE_SIZE},
+		FORM_CLIP_LIMIT_NAME:        {"minimum": MIN_CLIP_LIMIT, "maximum": MAX_CLIP_LIMIT, "default": DEFAULT_CLIP_LIMIT},
+		FORM_EDGE_METHOD_NAME:       {"enum": getEdgeMethods(), "default": DEFAULT_EDGE_METHOD},
+		FORM_EDGE_LOW_NAME:          {"minimum": MIN_EDGE, "maximum": MAX_EDGE, "default": DEFAULT_EDGE_LOW},
+		FORM_EDGE_HIGH_NAME:         {"minimum": MIN_EDGE, "maximum": MAX_EDGE, "default": DEFAULT_EDGE_HIGH},
+		FORM_BLUR_NAME:              {"minimum": MIN_BLUR, "maximum": MAX_BLUR, "default": DEFAULT_BLUR},
+		FORM_FILL_NAME:              {"minimum": MIN_FILL, "maximum": MAX_FILL, "default": DEFAULT_FILL},
+		FORM_SHARPEN_RADIUS_NAME:    {"minimum": MIN_SHARPEN_RADIUS, "maximum": MAX_SHARPEN_RADIUS, "default": DEFAULT_SHARPEN_RADIUS},
+		FORM_SHARPEN_AMOUNT_NAME:    {"minimum": MIN_SHARPEN_AMOUNT, "maximum": MAX_SHARPEN_AMOUNT, "default": DEFAULT_SHARPEN_AMOUNT},
+		FORM_SHARPEN_THRESHOLD_NAME: {"minimum": MIN_SHARPEN_THRESHOLD, "maximum": MAX_SHARPEN_THRESHOLD, "default": DEFAULT_SHARPEN_THRESHOLD},
+		FORM_CELL_SIZE_NAME:         {"minimum": MIN_CELL_SIZE, "maximum": MAX_CELL_SIZE, "default": DEFAULT_CELL_SIZE},
+		FORM_ANGLE_NAME:             {"minimum": MIN_ANGLE, "maximum": MAX_ANGLE, "default": DEFAULT_ANGLE},
+		FORM_KERNEL_NAME:            {"description": "Required by, and only allowed with, the custom style. Sent as a JSON encoded string in form data & query strings."},
+		FORM_DIFFUSION_NAME:         {"minimum": MIN_DIFFUSION, "maximum": MAX_DIFFUSION, "default": DEFAULT_DIFFUSION},
+		FORM_CLAMP_NAME:             {"default": DEFAULT_CLAMPED},
+		FORM_CLAMP_MIN_NAME:         {"minimum": MIN_CLAMP, "maximum": MAX_CLAMP, "default": DEFAULT_CLAMP_MIN},
+		FORM_CLAMP_MAX_NAME:         {"minimum": MIN_CLAMP, "maximum": MAX_CLAMP, "default": DEFAULT_CLAMP_MAX},
+		FORM_FORMAT_NAME:            {"enum": getFormats(), "default": DEFAULT_FORMAT},
+	}
+}
+
+// getJSONFieldName returns the JSON name of a struct field, and whether it is optional (a pointer, or tagged with omitempty).
+// Returns an empty name if the field is not encoded.
+func getJSONFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" || !field.IsExported() {
+		return "", false
+	}
+
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, field.Type.Kind() == reflect.Pointer || strings.Contains(options, "omitempty")
+}
+
+// getTypeSchema returns the OpenAPI schema of a Go type, as encoded by encoding/json. Named struct types are added to
+// `schemas`, and referenced rather than inlined. Embedded structs have their fields promoted, as they are when encoded.
+func getTypeSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	if t == reflect.TypeOf(CheckboxBool("")) {
+		return map[string]any{"type": "boolean"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return getTypeSchema(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": getTypeSchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": getTypeSchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		if _, ok := schemas[t.Name()]; !ok {
+			schemas[t.Name()] = nil
+			schemas[t.Name()] = getStructSchema(t, schemas)
+		}
+		return map[string]any{"$ref": SCHEMA_REF_PREFIX + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+// getStructSchema returns the OpenAPI object schema of a struct type, with one property per encoded field.
+func getStructSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	properties, required := map[string]any{}, []string{}
+
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+				addFields(field.Type)
+				continue
+			}
+
+			name, isOptional := getJSONFieldName(field)
+			if name == "" {
+				continue
+			}
+
+			properties[name] = getTypeSchema(field.Type, schemas)
+			if !isOptional {
+				required = append(required, name)
+			}
+		}
+	}
+	addFields(t)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// getOptionsSchema returns the schema of the FormData struct, with the constraints of each field applied. Every option is
+// optional. Referenced properties are wrapped in allOf, since siblings of $ref are ignored by OpenAPI 3.0.
+func getOptionsSchema(schemas map[string]any) map[string]any {
+	schema := getStructSchema(reflect.TypeOf(FormData{}), schemas)
+	delete(schema, "required")
+	properties := schema["properties"].(map[string]any)
+
+	for name, constraints := range getFieldConstraints() {
+		property := properties[name].(map[string]any)
+		if _, ok := property["$ref"]; ok {
+			property = map[string]any{"allOf": []any{property}}
+		}
+		for keyword, value := range constraints {
+			property[keyword] = value
+		}
+		properties[name] = property
+	}
+
+	return schema
+}
+
+// getFormOptionSchemas returns the schema of each option, as sent in form data or a query string. Unlike JSON, checkboxes
+// are only checked by the value "on", and kernels are sent as a JSON encoded string.
+func getFormOptionSchemas(optionsSchema map[string]any) map[string]any {
+	properties := map[string]any{}
+	for name, property := range optionsSchema["properties"].(map[string]any) {
+		properties[name] = property
+	}
+
+	properties[FORM_INVERT_NAME] = map[string]any{"type": "string", "enum": []string{string(CHECKBOX_CHECKED)}}
+	properties[FORM_CLAMP_NAME] = map[string]any{"type": "string", "enum": []string{string(CHECKBOX_CHECKED)}}
+	properties[FORM_KERNEL_NAME] = map[string]any{"type": "string", "description": "JSON encoded DitherKernel."}
+	return properties
+}
+
+// getQueryParameters returns the query parameters accepted alongside a raw image body, one per FormData field.
+func getQueryParameters(optionsSchema map[string]any) []any {
+	properties := getFormOptionSchemas(optionsSchema)
+	t := reflect.TypeOf(FormData{})
+
+	parameters := []any{}
+	for i := range t.NumField() {
+		name, _ := getJSONFieldName(t.Field(i))
+		parameters = append(parameters, map[string]any{
+			"name":   name,
+			"in":     "query",
+			"schema": properties[name],
+		})
+	}
+	return parameters
+}
+
+// buildOpenAPIDocument generates the OpenAPI 3 document describing the versioned API, using the request & response types
+// of the API as the source of each schema.
+func buildOpenAPIDocument() map[string]any {
+	schemas := map[string]any{}
+	optionsName := reflect.TypeOf(FormData{}).Name()
+	optionsSchema := getOptionsSchema(schemas)
+	schemas[optionsName] = optionsSchema
+
+	multipartProperties := getFormOptionSchemas(optionsSchema)
+	multipartProperties[FORM_IMAGE_NAME] = map[string]any{"type": "string", "format": "binary"}
+	multipartSchema := map[string]any{"type": "object", "properties": multipartProperties, "required": []string{FORM_IMAGE_NAME}}
+
+	jsonRequestSchema := map[string]any{
+		"allOf": []any{
+			map[string]any{"$ref": SCHEMA_REF_PREFIX + optionsName},
+			map[string]any{
+				"type":       "object",
+				"properties": map[string]any{FORM_IMAGE_NAME: map[string]any{"type": "string", "format": "byte", "description": "Base64 encoded image, optionally as a data URL. The standard & URL-safe alphabets are accepted, with or without padding."}},
+				"required":   []string{FORM_IMAGE_NAME},
+			},
+		},
+	}
+
+	binarySchema := map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}
+	asciiSchema := getTypeSchema(reflect.TypeOf([]string{}), schemas)
+	detailedSchema := getTypeSchema(reflect.TypeOf(DetailedResponse{}), schemas)
+	errorSchema := getTypeSchema(reflect.TypeOf(ErrorResponse{}), schemas)
+	capabilitiesSchema := getTypeSchema(reflect.TypeOf(Capabilities{}), schemas)
+	errorResponse := func(description string) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content":     map[string]any{binding.MIMEJSON: map[string]any{"schema": errorSchema}},
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "image2ascii",
+			"version":     API_VERSION,
+			"description": "Converts PNG & JPEG images to braille ASCII art. `POST /api` is an alias of `POST " + API_V1_PREFIX + "/convert`.",
+		},
+		"paths": map[string]any{
+			API_V1_PREFIX + "/convert": map[string]any{
+				"post": map[string]any{
+					"operationId": "convert",
+					"summary":     "Convert an image to ASCII",
+					"description": "With a raw image body, options are passed as query parameters instead.",
+					"parameters": append(getQueryParameters(optionsSchema), map[string]any{
+						"name":        "If-None-Match",
+						"in":          "header",
+						"description": "The ETag of a previous response. If it matches, the server responds with a 304 instead of the ASCII.",
+						"schema":      map[string]any{"type": "string"},
+					}),
+					"requestBody": map[string]any{
+						"required": true,
+						"content": map[string]any{
+							binding.MIMEMultipartPOSTForm: map[string]any{"schema": multipartSchema},
+							binding.MIMEJSON:              map[string]any{"schema": jsonRequestSchema},
+							MIME_PNG:                      binarySchema,
+							MIME_JPEG:                     binarySchema,
+							MIME_JPG:                      binarySchema,
+							MIME_OCTET_STREAM:             binarySchema,
+						},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The generated ASCII, in the requested format.",
+							"headers": map[string]any{
+								"ETag":    map[string]any{"description": "Weak tag of the ASCII & format.", "schema": map[string]any{"type": "string"}},
+								"X-Cache": map[string]any{"description": "Whether the ASCII was served from the cache.", "schema": map[string]any{"type": "string", "enum": []string{CACHE_HIT, CACHE_MISS}}},
+							},
+							"content": map[string]any{
+								binding.MIMEJSON:  map[string]any{"schema": asciiSchema},
+								binding.MIMEPlain: map[string]any{"schema": map[string]any{"type": "string"}},
+								MIME_DETAILED:     map[string]any{"schema": detailedSchema},
+							},
+						},
+						"304": map[string]any{"description": "The If-None-Match header matches the ETag of the ASCII, so it is not sent again."},
+						"400": errorResponse("The image could not be read, or at least one option failed validation."),
+						"413": errorResponse("The request body is larger than the configured maximum."),
+						"415": errorResponse("The content type of the request body is not supported."),
+						"429": errorResponse("The client has exceeded it's rate limit, or too many conversions are in progress. Retry after the number of seconds in the Retry-After header."),
+					},
+				},
+			},
+			API_V1_PREFIX + "/capabilities": map[string]any{
+				"get": map[string]any{
+					"operationId": "getCapabilities",
+					"summary":     "Get the supported styles, formats, limits & defaults",
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The capabilities of the API.",
+							"content":     map[string]any{binding.MIMEJSON: map[string]any{"schema": capabilitiesSchema}},
+						},
+					},
+				},
+			},
+			API_V1_PREFIX + "/openapi.json": map[string]any{
+				"get": map[string]any{
+					"operationId": "getOpenAPI",
+					"summary":     "Get this document",
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The OpenAPI document of the API.",
+							"content":     map[string]any{binding.MIMEJSON: map[string]any{"schema": map[string]any{"type": "object"}}},
+						},
+					},
+				},
+			},
+		},
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+// openAPIDocument is generated on first use, since it never changes while the server is running.
+var openAPIDocument = sync.OnceValue(buildOpenAPIDocument)
+
+// getOpenAPI is the function executed when a user does a GET request to "/api/v1/openapi.json".
+// This simply returns the OpenAPI document of the API.
+func getOpenAPI(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, openAPIDocument())
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// minimum number of rows given to each goroutine by parallelRows, so that small matrices are not split into more goroutines than
+// they are worth
+const MIN_ROWS_PER_WORKER = 16
+
+// parallelRows calls fn for every row index between 0 and `height`, splitting the rows into contiguous bands that are processed
+// concurrently, with up to one band per CPU. fn must only write to the row it is given, and must not read any row that another
+// call writes to.
+func parallelRows(height int, fn func(y int)) {
+	workers := min(runtime.GOMAXPROCS(0), height/MIN_ROWS_PER_WORKER)
+	if workers <= 1 {
+		for y := range height {
+			fn(y)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	band := (height + workers - 1) / workers
+	for start := 0; start < height; start += band {
+		end := min(start+band, height)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for y := start; y < end; y++ {
+				fn(y)
+			}
+		}()
+	}
+	wg.Wait()
+}
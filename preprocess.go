@@ -0,0 +1,296 @@
+package main
+
+import "math"
+
+// clampUnit restricts v to a number between 0.0 and 1.0.
+func clampUnit(v float64) float64 {
+	return math.Max(0.0, math.Min(1.0, v))
+}
+
+// getContrastFactor maps a contrast value between `MIN_CONTRAST` and `MAX_CONTRAST` to a multiplier applied around the
+// midpoint of the grayscale range. A contrast of 0 returns 1.0 (no change), `MIN_CONTRAST` flattens the image entirely, and
+// `MAX_CONTRAST` quadruples the distance of each pixel from the midpoint.
+func getContrastFactor(contrast float64) float64 {
+	factor := (contrast + 100.0) / 100.0
+	return factor * factor
+}
+
+// isAdjustmentNeeded determines whether any of the tonal adjustments in form differ from their defaults.
+// If not, the grayscale matrix can be left untouched.
+func isAdjustmentNeeded(form FormData) bool {
+	return *form.Brightness != DEFAULT_BRIGHTNESS ||
+		*form.Contrast != DEFAULT_CONTRAST ||
+		*form.Gamma != DEFAULT_GAMMA ||
+		*form.BlackPoint != DEFAULT_BLACK_POINT ||
+		*form.WhitePoint != DEFAULT_WHITE_POINT
+}
+
+// adjustLuminance applies each tonal adjustment to a single luminance value between 0.0 and 1.0, in the following order:
+// levels (black & white point), gamma, brightness, and finally contrast. The result is clamped between 0.0 and 1.0.
+func adjustLuminance(luminance, blackPoint, whitePoint, gamma, brightness, contrastFactor float64) float64 {
+	v := clampUnit((luminance - blackPoint) / (whitePoint - blackPoint))
+	v = math.Pow(v, 1.0/gamma)
+	v += brightness
+	v = (v-0.5)*contrastFactor + 0.5
+
+	return clampUnit(v)
+}
+
+// adjustGrayscaleMatrix applies the tonal adjustments defined in form (brightness, contrast, gamma & levels) to each element
+// of `grayscaleMatrix`, in place. Form is expected to be validated before calling this function.
+func adjustGrayscaleMatrix(grayscaleMatrix *LuminanceBuffer, form FormData) {
+	if !isAdjustmentNeeded(form) {
+		return
+	}
+
+	blackPoint, whitePoint := *form.BlackPoint/MAX_LEVEL, *form.WhitePoint/MAX_LEVEL
+	brightness := *form.Brightness / MAX_BRIGHTNESS
+	contrastFactor := getContrastFactor(*form.Contrast)
+
+	parallelRows(grayscaleMatrix.Height, func(y int) {
+		row := grayscaleMatrix.Row(y)
+		for x, v := range row {
+			row[x] = float32(adjustLuminance(float64(v), blackPoint, whitePoint, *form.Gamma, brightness, contrastFactor))
+		}
+	})
+}
+
+// number of bins used when computing a histogram of the grayscale matrix
+const HISTOGRAM_BINS = 256
+
+// getHistogramBin maps a luminance value between 0.0 and 1.0 to its histogram bin, a number between 0 and `HISTOGRAM_BINS`-1.
+func getHistogramBin(luminance float64) int {
+	return min(int(clampUnit(luminance)*HISTOGRAM_BINS), HISTOGRAM_BINS-1)
+}
+
+// getEqualizationMapping takes a histogram, and returns a slice that maps each bin to it's equalized luminance, a value between
+// 0.0 and 1.0, using the normalized cumulative distribution of the histogram.
+// If every sample falls in a single bin, the histogram cannot be equalized, so each bin maps to it's own midpoint.
+// For more information, see: https://en.wikipedia.org/wiki/Histogram_equalization
+func getEqualizationMapping(histogram []float64) []float64 {
+	mapping := make([]float64, len(histogram))
+	cdf, cdfMin := 0.0, 0.0
+	for i, count := range histogram {
+		cdf += count
+		if cdfMin == 0.0 {
+			cdfMin = cdf
+		}
+		mapping[i] = cdf
+	}
+
+	for i := range mapping {
+		if cdf == cdfMin {
+			mapping[i] = (float64(i) + 0.5) / float64(len(mapping))
+		} else {
+			mapping[i] = clampUnit((mapping[i] - cdfMin) / (cdf - cdfMin))
+		}
+	}
+
+	return mapping
+}
+
+// equalizeGlobal performs global histogram equalization on `grayscaleMatrix`, in place, such that luminance values are spread
+// evenly across the full range between 0.0 and 1.0.
+func equalizeGlobal(grayscaleMatrix *LuminanceBuffer) {
+	histogram := make([]float64, HISTOGRAM_BINS)
+	for y := range grayscaleMatrix.Height {
+		for _, v := range grayscaleMatrix.Row(y) {
+			histogram[getHistogramBin(float64(v))]++
+		}
+	}
+
+	mapping := getEqualizationMapping(histogram)
+	parallelRows(grayscaleMatrix.Height, func(y int) {
+		row := grayscaleMatrix.Row(y)
+		for x, v := range row {
+			row[x] = float32(mapping[getHistogramBin(float64(v))])
+		}
+	})
+}
+
+// clipHistogram limits each bin of histogram to `clipLimit` times the average bin count, redistributing the excess evenly
+// across every bin. This is what limits the contrast amplification of CLAHE.
+func clipHistogram(histogram []float64, clipLimit float64) {
+	total := 0.0
+	for _, count := range histogram {
+		total += count
+	}
+
+	limit := clipLimit * total / float64(len(histogram))
+	excess := 0.0
+	for i, count := range histogram {
+		if count > limit {
+			excess += count - limit
+			histogram[i] = limit
+		}
+	}
+
+	redistributed := excess / float64(len(histogram))
+	for i := range histogram {
+		histogram[i] += redistributed
+	}
+}
+
+// getTileMappings divides `grayscaleMatrix` into square tiles with sides of length `tileSize`, and returns the clipped
+// equalization mapping of each tile, indexed as mappings[tileY][tileX].
+func getTileMappings(grayscaleMatrix *LuminanceBuffer, tileSize int, clipLimit float64) [][][]float64 {
+	height, width := grayscaleMatrix.Height, grayscaleMatrix.Width
+	tilesX, tilesY := (width+tileSize-1)/tileSize, (height+tileSize-1)/tileSize
+
+	mappings := make([][][]float64, tilesY)
+	for tileY := range mappings {
+		mappings[tileY] = make([][]float64, tilesX)
+		for tileX := range mappings[tileY] {
+			histogram := make([]float64, HISTOGRAM_BINS)
+			for y := tileY * tileSize; y < min((tileY+1)*tileSize, height); y++ {
+				for x := tileX * tileSize; x < min((tileX+1)*tileSize, width); x++ {
+					histogram[getHistogramBin(float64(grayscaleMatrix.At(x, y)))]++
+				}
+			}
+
+			clipHistogram(histogram, clipLimit)
+			mappings[tileY][tileX] = getEqualizationMapping(histogram)
+		}
+	}
+
+	return mappings
+}
+
+// getTileNeighbors maps a pixel coordinate `n` to the indices of the two nearest tile centers along one axis, as well as the
+// weight of the second tile, a value between 0.0 and 1.0, used for linear interpolation between the two tiles.
+func getTileNeighbors(n, tileSize, tiles int) (int, int, float64) {
+	position := (float64(n)+0.5)/float64(tileSize) - 0.5
+	first := int(math.Floor(position))
+	weight := position - float64(first)
+
+	if first < 0 {
+		return 0, 0, 0.0
+	}
+	if first >= tiles-1 {
+		return tiles - 1, tiles - 1, 0.0
+	}
+	return first, first + 1, weight
+}
+
+// equalizeAdaptive performs contrast-limited adaptive histogram equalization (CLAHE) on `grayscaleMatrix`, in place.
+// Each pixel is equalized using the mappings of the four nearest tiles, bilinearly interpolated to avoid visible tile borders.
+// For more information, see: https://en.wikipedia.org/wiki/Adaptive_histogram_equalization#Contrast_Limited_AHE
+func equalizeAdaptive(grayscaleMatrix *LuminanceBuffer, tileSize int, clipLimit float64) {
+	mappings := getTileMappings(grayscaleMatrix, tileSize, clipLimit)
+	tilesX, tilesY := len(mappings[0]), len(mappings)
+
+	parallelRows(grayscaleMatrix.Height, func(y int) {
+		top, bottom, wy := getTileNeighbors(y, tileSize, tilesY)
+		row := grayscaleMatrix.Row(y)
+		for x, v := range row {
+			left, right, wx := getTileNeighbors(x, tileSize, tilesX)
+			bin := getHistogramBin(float64(v))
+
+			topValue := (1-wx)*mappings[top][left][bin] + wx*mappings[top][right][bin]
+			bottomValue := (1-wx)*mappings[bottom][left][bin] + wx*mappings[bottom][right][bin]
+			row[x] = float32((1-wy)*topValue + wy*bottomValue)
+		}
+	})
+}
+
+// equalizeGrayscaleMatrix applies the histogram equalization method defined in form to `grayscaleMatrix`, in place.
+// An empty matrix has nothing to equalize, and no tiles to divide it into, so it is left untouched.
+// Form is expected to be validated before calling this function.
+func equalizeGrayscaleMatrix(grayscaleMatrix *LuminanceBuffer, form FormData) {
+	if grayscaleMatrix.Width == 0 || grayscaleMatrix.Height == 0 {
+		return
+	}
+
+	switch *form.Equalize {
+	case EQUALIZE_GLOBAL:
+		equalizeGlobal(grayscaleMatrix)
+	case EQUALIZE_CLAHE:
+		equalizeAdaptive(grayscaleMatrix, *form.TileSize, *form.ClipLimit)
+	}
+}
+
+// getGaussianKernel returns a normalized, one-dimensional gaussian kernel with standard deviation `sigma`, with a radius of
+// 3 standard deviations on each side of the center.
+func getGaussianKernel(sigma float64) []float64 {
+	radius := int(math.Ceil(3.0 * sigma))
+	kernel := make([]float64, 2*radius+1)
+
+	sum := 0.0
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-(d * d) / (2.0 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	return kernel
+}
+
+// gaussianBlur returns a copy of `grayscaleMatrix`, blurred with a gaussian of standard deviation `sigma`.
+// The blur is applied as two separable passes (horizontal, then vertical), with pixels outside the matrix clamped to the edge.
+// Each pass writes to a separate matrix, so that the rows of each pass can be computed independently.
+// The copy is taken from the buffer pool, so it should be released once it is no longer used.
+// For more information, see: https://en.wikipedia.org/wiki/Gaussian_blur
+func gaussianBlur(grayscaleMatrix *LuminanceBuffer, sigma float64) *LuminanceBuffer {
+	if sigma <= 0.0 {
+		return cloneLuminanceBuffer(grayscaleMatrix)
+	}
+
+	height, width := grayscaleMatrix.Height, grayscaleMatrix.Width
+	kernel := getGaussianKernel(sigma)
+	radius := len(kernel) / 2
+
+	horizontal := getLuminanceBuffer(width, height)
+	defer releaseLuminanceBuffer(horizontal)
+	parallelRows(height, func(y int) {
+		source, row := grayscaleMatrix.Row(y), horizontal.Row(y)
+		for x := range row {
+			sum := 0.0
+			for i, weight := range kernel {
+				sum += weight * float64(source[min(max(x+i-radius, 0), width-1)])
+			}
+			row[x] = float32(sum)
+		}
+	})
+
+	blurred := getLuminanceBuffer(width, height)
+	parallelRows(height, func(y int) {
+		row := blurred.Row(y)
+		for x := range row {
+			sum := 0.0
+			for i, weight := range kernel {
+				sum += weight * float64(horizontal.At(x, min(max(y+i-radius, 0), height-1)))
+			}
+			row[x] = float32(sum)
+		}
+	})
+
+	return blurred
+}
+
+// sharpenGrayscaleMatrix applies an unsharp mask to `grayscaleMatrix`, in place, using the radius, amount & threshold defined
+// in form. Each pixel is pushed away from it's blurred counterpart by `amount` percent of the difference, but only when that
+// difference is at least `threshold`, which prevents noise in flat regions from being amplified.
+// Form is expected to be validated before calling this function.
+// For more information, see: https://en.wikipedia.org/wiki/Unsharp_masking
+func sharpenGrayscaleMatrix(grayscaleMatrix *LuminanceBuffer, form FormData) {
+	if *form.SharpenAmount == MIN_SHARPEN_AMOUNT {
+		return
+	}
+
+	blurred := gaussianBlur(grayscaleMatrix, *form.SharpenRadius)
+	defer releaseLuminanceBuffer(blurred)
+	amount, threshold := *form.SharpenAmount/100.0, *form.SharpenThreshold/MAX_SHARPEN_THRESHOLD
+
+	parallelRows(grayscaleMatrix.Height, func(y int) {
+		row, blurredRow := grayscaleMatrix.Row(y), blurred.Row(y)
+		for x, v := range row {
+			difference := float64(v) - float64(blurredRow[x])
+			if math.Abs(difference) >= threshold {
+				row[x] = float32(clampUnit(float64(v) + amount*difference))
+			}
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// width & height of the matrix equalized by the tests
+const EQUALIZE_TEST_SIZE = 64
+
+// newLowContrastMatrix returns a matrix holding a horizontal gradient, with luminance spread across only [0.4, 0.6).
+func newLowContrastMatrix() *LuminanceBuffer {
+	grayscale := getLuminanceBuffer(EQUALIZE_TEST_SIZE, EQUALIZE_TEST_SIZE)
+	for y := range grayscale.Height {
+		for x := range grayscale.Width {
+			grayscale.Set(x, y, float32(0.4+0.2*float64(x)/EQUALIZE_TEST_SIZE))
+		}
+	}
+	return grayscale
+}
+
+// equalizeLowContrastMatrix equalizes a low contrast matrix with `equalize`, and returns the minimum & maximum luminance of the
+// result, along with a histogram of it's luminance, split into quarters. Fails the test if any row is no longer in order.
+func equalizeLowContrastMatrix(t *testing.T, equalize string, tileSize int, clipLimit float64) (float32, float32, [4]int) {
+	t.Helper()
+
+	grayscale := newLowContrastMatrix()
+	defer releaseLuminanceBuffer(grayscale)
+	equalizeGrayscaleMatrix(grayscale, FormData{Equalize: &equalize, TileSize: &tileSize, ClipLimit: &clipLimit})
+
+	minVal, maxVal, quarters := float32(1), float32(0), [4]int{}
+	for y := range grayscale.Height {
+		row := grayscale.Row(y)
+		for x, v := range row {
+			if v < 0 || v > 1 {
+				t.Fatalf("luminance at (%d, %d) is out of range: %v", x, y, v)
+			}
+			if x > 0 && v < row[x-1] {
+				t.Fatalf("luminance at (%d, %d) is out of order: %v after %v", x, y, v, row[x-1])
+			}
+			minVal, maxVal = min(minVal, v), max(maxVal, v)
+			quarters[min(int(v*4), 3)]++
+		}
+	}
+	return minVal, maxVal, quarters
+}
+
+func TestEqualizeGrayscaleMatrixEmpty(t *testing.T) {
+	for _, equalize := range getEqualizations() {
+		t.Run(equalize, func(t *testing.T) {
+			grayscale := getLuminanceBuffer(20, 0)
+			defer releaseLuminanceBuffer(grayscale)
+
+			tileSize, clipLimit := DEFAULT_TILE_SIZE, DEFAULT_CLIP_LIMIT
+			equalizeGrayscaleMatrix(grayscale, FormData{Equalize: &equalize, TileSize: &tileSize, ClipLimit: &clipLimit})
+		})
+	}
+}
+
+// TestEqualizeGlobal ensures that global equalization stretches a low contrast matrix across the full range, with an even
+// histogram.
+func TestEqualizeGlobal(t *testing.T) {
+	minVal, maxVal, quarters := equalizeLowContrastMatrix(t, EQUALIZE_GLOBAL, DEFAULT_TILE_SIZE, DEFAULT_CLIP_LIMIT)
+	if minVal != 0 || maxVal != 1 {
+		t.Errorf("expected luminance between 0 & 1, got %v & %v", minVal, maxVal)
+	}
+
+	total := EQUALIZE_TEST_SIZE * EQUALIZE_TEST_SIZE
+	for i, count := range quarters {
+		if fraction := float64(count) / float64(total); fraction < 0.2 || fraction > 0.3 {
+			t.Errorf("expected quarter %d to hold about 25%% of the matrix, got %.1f%%", i, fraction*100)
+		}
+	}
+}
+
+// TestEqualizeAdaptiveClipLimit equalizes a low contrast matrix as a single tile, which CLAHE equalizes like global
+// equalization, except that it's histogram is clipped. A higher clip limit allows more contrast, up to the full range once
+// nothing is clipped, while the lowest clip limit leaves the matrix close to it's original range.
+func TestEqualizeAdaptiveClipLimit(t *testing.T) {
+	cases := []struct {
+		ClipLimit float64
+		MinSpread float32
+		MaxSpread float32
+	}{
+		{ClipLimit: MIN_CLIP_LIMIT, MinSpread: 0.2, MaxSpread: 0.45},
+		{ClipLimit: DEFAULT_CLIP_LIMIT, MinSpread: 0.45, MaxSpread: 0.65},
+		{ClipLimit: MAX_CLIP_LIMIT, MinSpread: 0.99, MaxSpread: 1},
+	}
+
+	previous := float32(0)
+	for _, c := range cases {
+		t.Run(fmt.Sprint(c.ClipLimit), func(t *testing.T) {
+			minVal, maxVal, _ := equalizeLowContrastMatrix(t, EQUALIZE_CLAHE, EQUALIZE_TEST_SIZE, c.ClipLimit)
+			spread := maxVal - minVal
+			if spread < c.MinSpread || spread > c.MaxSpread {
+				t.Errorf("expected luminance to spread between %v & %v, got %v (%v to %v)", c.MinSpread, c.MaxSpread, spread, minVal, maxVal)
+			}
+			if spread <= previous {
+				t.Errorf("expected a higher clip limit to spread luminance further than %v, got %v", previous, spread)
+			}
+			previous = spread
+		})
+	}
+}
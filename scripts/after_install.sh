@@ -24,7 +24,24 @@ LOG_FILE="/var/log/image2ascii/deploy.log"
     echo 'Rebuilding output.css file...'
     npx tailwindcss -i ./static/input.css -o ./static/styles.css
     echo 'Recompiling go binary...'
-    go build -buildvcs=false
+    # the commit is read from git when the bundle is a checkout. Otherwise, it is looked up from the deployment when the AWS CLI
+    # is available, and allowed to read it. The lookup is optional, so that it can never fail the install.
+    COMMIT=''
+    if [ -d .git ]; then
+        COMMIT=$(git rev-parse HEAD 2>/dev/null) || COMMIT=''
+    fi
+    if [ -z "$COMMIT" ] && [ -n "$DEPLOYMENT_ID" ] && command -v aws >/dev/null 2>&1; then
+        COMMIT=$(timeout 30 aws deploy get-deployment --deployment-id "$DEPLOYMENT_ID" \
+            --query 'deploymentInfo.revision.gitHubLocation.commitId' --output text 2>/dev/null) || COMMIT=''
+    fi
+    if [ -z "$COMMIT" ] || [ "$COMMIT" = 'None' ]; then
+        echo 'Could not determine commit of deployment, building without it'
+        COMMIT=''
+    fi
+    VERSION="${COMMIT:0:12}"
+    BUILD_TIME=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+    go build -buildvcs=false -ldflags "-X main.version=${VERSION:-$DEPLOYMENT_ID} -X main.commit=$COMMIT -X main.buildTime=$BUILD_TIME"
+    echo "Built version ${VERSION:-$DEPLOYMENT_ID} (commit: ${COMMIT:-unknown})"
 
     echo 'after_install.sh completed successfully'
 
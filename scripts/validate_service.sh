@@ -0,0 +1,25 @@
+#!/bin/bash
+LOG_FILE="/var/log/image2ascii/deploy.log"
+READY_URL="http://localhost:8080/readyz"
+ATTEMPTS=30
+
+{
+    echo
+    echo '=================================================='
+    echo
+    echo 'Starting validate_service.sh: '
+    echo "Waiting for $READY_URL..."
+    for attempt in $(seq 1 $ATTEMPTS); do
+        if curl --silent --fail --max-time 5 "$READY_URL"; then
+            echo
+            echo 'image2ascii service is ready'
+            echo 'validate_service.sh completed successfully'
+            exit 0
+        fi
+        echo "Attempt $attempt/$ATTEMPTS failed, retrying in 2 seconds..."
+        sleep 2
+    done
+
+    echo 'image2ascii service failed to become ready'
+    exit 1
+} >> "$LOG_FILE" 2>&1
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+)
+
+// newServer returns a http.Server that serves `handler` on the configured address, with the configured timeouts.
+func newServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              config.Address,
+		Handler:           handler,
+		ReadHeaderTimeout: config.ReadTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+}
+
+// runServer starts server, and blocks until it stops. On SIGINT or SIGTERM, the server stops accepting new connections, and
+// waits up to the configured shutdown timeout for active requests to finish before returning.
+// Returns an error if the server fails to start, or fails to drain active requests in time.
+func runServer(server *http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	slog.Info("listening", slog.String("address", server.Addr))
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	stop()
+	isShuttingDown.Store(true)
+	slog.Info("shutting down, waiting for active requests to finish", slog.String("timeout", config.ShutdownTimeout.String()))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -18,8 +18,7 @@ document.addEventListener('DOMContentLoaded', function() {
     const customSize = this.getElementById('custom-size');
     const widthInput = this.getElementById('width');
     const heightInput = this.getElementById('height');
-    const exposure = this.getElementById('exposure');
-    const exposureValue = this.getElementById('exposure-value');
+    const sliders = form.querySelectorAll('input[type="range"]');
     const uploadBtn = this.getElementById('upload');
     const error = this.getElementById('error');
     const imagePlaceholder = this.getElementById('img-placeholder');
@@ -43,11 +42,12 @@ document.addEventListener('DOMContentLoaded', function() {
     const widthAndHeightInputs = customSize.getElementsByTagName('input');
 
     /* ===== VARIABLES ===== */
-    const MAX_LENGTH = 500;
+    const MAX_LENGTH = parseInt(widthInput.max);
     const THEME = "theme";
     const LIGHT_THEME = "light";
     const DARK_THEME = "dark";
     const FLOAT_IN_ANIMATION = 'animate-floatin';
+    const INVALID_FIELD_CLASSES = ['!border-red-500', 'dark:!border-red-500'];
     const size = {
         twitch: {
             width: 30,
@@ -131,6 +131,57 @@ document.addEventListener('DOMContentLoaded', function() {
         error.textContent = '';
     }
 
+    /**
+     * Gets the element that should be highlighted when the form field named `name` is invalid. Sliders highlight their
+     * number input, and selects highlight their bordered wrapper.
+     * 
+     * @param {string} name - The name of a form field.
+     * @returns {HTMLElement | null}
+     */
+    function getFieldElement(name) {
+        const input = form.querySelector(`[name="${name}"]`);
+        if (!input || input.type === 'hidden') {
+            return null;
+        }
+
+        if (input.type === 'range') {
+            return document.getElementById(`${input.id}-value`);
+        }
+        if (input.tagName === 'SELECT') {
+            return input.parentElement;
+        }
+        return input;
+    }
+
+    /**
+     * Highlights each form field that failed validation, expanding any collapsed section that contains one.
+     * 
+     * @param {{field: string}[]} fieldErrors - The `errors` array of a validation error response.
+     */
+    function highlightInvalidFields(fieldErrors) {
+        fieldErrors.forEach(({ field }) => {
+            const element = getFieldElement(field);
+            if (!element) {
+                return;
+            }
+
+            element.classList.add(...INVALID_FIELD_CLASSES);
+            const section = element.closest('details');
+            if (section) {
+                section.open = true;
+            }
+        });
+    }
+
+    /**
+     * Removes the highlight from every form field
+     */
+    function clearInvalidFields() {
+        form.querySelectorAll(INVALID_FIELD_CLASSES.map(c => `.${CSS.escape(c)}`).join(', ')).forEach(element => {
+            element.classList.remove(...INVALID_FIELD_CLASSES);
+        });
+    }
+
     /**
      * Hides user options in the event of an error, which is described by `message`
      * 
@@ -324,7 +375,7 @@ document.addEventListener('DOMContentLoaded', function() {
      * @param {HTMLFormElement} form Form element with user selections.
      */
     async function getOutput(form) {
-        const action = form.action + "api";
+        const action = form.action + "api/v1/convert";
         const method = form.method;
         const formData = new FormData(form);
         formData.delete('size');
@@ -335,7 +386,9 @@ document.addEventListener('DOMContentLoaded', function() {
         });
         let data = await response.json();
 
+        clearInvalidFields();
         if (response.status !== 200 || "error" in data) {
+            highlightInvalidFields(data.errors ?? []);
             throw new Error(data.error);
         }
 
@@ -664,9 +717,12 @@ document.addEventListener('DOMContentLoaded', function() {
     widthInput.addEventListener('change', widthInputChangeAction);
     heightInput.addEventListener('change', heightInputChangeAction);
 
-    // Exposure input events
-    exposure.addEventListener('input', event => exposureValue.value = event.target.value);
-    exposureValue.addEventListener('change', event => exposure.value = event.target.value);
+    // Slider input events (exposure & adjustments)
+    sliders.forEach(slider => {
+        const sliderValue = document.getElementById(`${slider.id}-value`);
+        slider.addEventListener('input', event => sliderValue.value = event.target.value);
+        sliderValue.addEventListener('change', event => slider.value = event.target.value);
+    });
 
     // Form events
     form.addEventListener('submit', formSubmitAction);